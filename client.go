@@ -2,7 +2,12 @@ package fayl
 
 import (
 	"context"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/redhajuanda/fayl/dialect"
 	"github.com/redhajuanda/fayl/parser"
 	"github.com/redhajuanda/perkakas/logger"
 
@@ -13,10 +18,19 @@ import (
 // It contains the database connection, runners, placeholder format, and logger.
 // It provides methods to run queries and manage transactions.
 type Client struct {
-	db          *DB
-	runners     map[string]string
-	placeholder parser.Placeholder
-	log         logger.Logger
+	db            *DB
+	registry      atomic.Pointer[registry]
+	registered    map[string]string
+	schemas       sync.Map
+	mu            sync.Mutex
+	dialect       dialect.Dialect
+	parserOptions []parser.Option
+	parser        parser.Parser
+	strict        bool
+	logInline     bool
+	placeholder   parser.Placeholder
+	log           logger.Logger
+	watcher       *watcher
 }
 
 // Run initializes a new Runner with the given runner code.
@@ -31,12 +45,194 @@ func (c *Client) Run(runner string) Runnerer {
 
 }
 
+// RunSQL initializes a new Runner with the given SQL query instead of a runner code.
+// The query goes through the same pipeline as the queries loaded from files,
+// it can use template actions, partials and a metadata header, but it is compiled on every run.
+func (c *Client) RunSQL(sql string) Runnerer {
+
+	return newRunner(runnerParams{
+		runnerCode:    inlineRunnerCode(sql),
+		sql:           &sql,
+		client:        c,
+		log:           c.log,
+		inTransaction: false,
+	})
+
+}
+
+// Register compiles the given SQL query and registers it under the given runner code,
+// so it can be run with Run like the queries loaded from files.
+// It fails if the runner code already exists or if the query does not compile.
+// Registered runners are kept when the query files are reloaded.
+func (c *Client) Register(runnerCode, sql string) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := c.registry.Load()
+
+	if _, ok := current.runners[runnerCode]; ok {
+		return errors.Errorf("duplicate runner code %s", runnerCode)
+	}
+
+	q, err := compileQuery(current.parser, runnerCode, sql)
+	if err != nil {
+		return err
+	}
+
+	// copy the runners, the current registry may be in use by concurrent runs
+	runners := make(map[string]query, len(current.runners)+1)
+	for code, q := range current.runners {
+		runners[code] = q
+	}
+	runners[runnerCode] = q
+
+	if c.registered == nil {
+		c.registered = make(map[string]string)
+	}
+	c.registered[runnerCode] = sql
+
+	c.registry.Store(&registry{
+		runners: runners,
+		parser:  current.parser,
+	})
+
+	return nil
+
+}
+
+// RegisterParams registers the param declarations of the given runner from a struct,
+// the params of the runner are then validated against them before its query is parsed.
+// The name of a param is the fayl tag of its field, its type is derived from the field type
+// and its rules are read from the param tag, e.g.
+//
+//	type CreateUserParams struct {
+//		Email  string `fayl:"email" param:"required,max=255"`
+//		Status string `fayl:"status" param:"oneof=active|inactive"`
+//	}
+//
+// The registered declarations replace the ones declared in the header of the query.
+// The queries run with RunSQL have no stable runner code, they declare their params in their header instead.
+func (c *Client) RegisterParams(runnerCode string, schema any) error {
+
+	if strings.HasPrefix(runnerCode, inlinePrefix) {
+		return errors.Errorf("failed to register params of runner %s: the params of a RunSQL query are declared in its header", runnerCode)
+	}
+
+	params, err := paramsFromStruct(schema)
+	if err != nil {
+		return errors.Wrapf(err, "failed to register params of runner %s", runnerCode)
+	}
+
+	c.schemas.Store(runnerCode, params)
+
+	return nil
+
+}
+
+// params returns the param declarations of the given runner,
+// the registered ones take precedence over the ones declared in the header of the query.
+func (c *Client) params(runnerCode string, q query) []Param {
+
+	if params, ok := c.schemas.Load(runnerCode); ok {
+		return params.([]Param)
+	}
+
+	return q.metadata.Params
+
+}
+
+// Close stops watching the query location for changes.
+// It is a no-op if the client was initialized without Option.Watch.
+func (c *Client) Close() error {
+
+	if c.watcher != nil {
+		c.watcher.stop()
+	}
+
+	return nil
+
+}
+
+// Metadata returns the metadata declared in the header of the given runner.
+// It returns false if the runner does not exist.
+func (c *Client) Metadata(runnerCode string) (Metadata, bool) {
+
+	q, ok := c.registry.Load().runners[runnerCode]
+	return q.metadata, ok
+
+}
+
+// HasRunner reports whether the given runner code exists.
+// It can be used at startup to assert that every runner code used by a service is loaded.
+func (c *Client) HasRunner(runnerCode string) bool {
+
+	_, ok := c.registry.Load().runners[runnerCode]
+	return ok
+
+}
+
+// Runners returns the codes of all the loaded runners, sorted alphabetically.
+func (c *Client) Runners() []string {
+
+	runners := c.registry.Load().runners
+
+	codes := make([]string, 0, len(runners))
+	for code := range runners {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	return codes
+
+}
+
+// runner returns the query stored under the given runner code.
+// The registry may be swapped concurrently by the watcher, so it is always read atomically.
+// It returns a *RunnerNotFoundError if the runner code does not exist.
+func (c *Client) runner(runnerCode string) (query, error) {
+
+	runners := c.registry.Load().runners
+
+	q, ok := runners[runnerCode]
+	if !ok {
+		return query{}, &RunnerNotFoundError{
+			RunnerCode: runnerCode,
+			Suggestion: suggestRunner(runnerCode, runners),
+		}
+	}
+
+	return q, nil
+
+}
+
+// suggestRunner returns the loaded runner code closest to the given one.
+// It returns an empty string if no runner code is close enough to be a likely typo.
+func suggestRunner(runnerCode string, runners map[string]query) string {
+
+	var (
+		suggestion string
+		best       = len(runnerCode)/3 + 1
+	)
+
+	for code := range runners {
+		d := levenshtein(strings.ToLower(runnerCode), strings.ToLower(code))
+		if d < best || (d == best && suggestion != "" && code < suggestion) {
+			suggestion, best = code, d
+		}
+	}
+
+	return suggestion
+
+}
+
 // WithTransaction initializes a new query with transaction.
 // it takes a context, and callback as input.
 // context is the context of the transaction.
 // callback is a function that will be executed in the transaction.
 // callback takes a context and tx as input.
 // tx is a struct that contains the transaction configs.
+// the transaction is committed if callback returns no error, and the error of the commit is returned.
 func (c *Client) WithTransaction(ctx context.Context, callback TxFunc) (out any, err error) {
 
 	// begin transaction
@@ -63,38 +259,34 @@ func (c *Client) WithTransaction(ctx context.Context, callback TxFunc) (out any,
 // handleTransaction handles the transaction logic for a given context.
 // It rolls back the transaction if a panic occurs or if an error is passed as input.
 // If no panic or error occurs, it commits the transaction.
-// It returns an error if there is a failure in rolling back or committing the transaction.
-func (c *Client) handleTransaction(ctx context.Context, errIn *error) (errOut error) {
+// A failure in committing the transaction is written to err, a failure in rolling it back wraps err.
+func (c *Client) handleTransaction(ctx context.Context, err *error) {
 
 	if p := recover(); p != nil {
 
 		c.log.WithContext(ctx).Debug("panic occurred, rolling back transaction")
 
-		err := c.db.Rollback(ctx)
-		if err != nil {
-			errOut = errors.Wrap(err, "failed to rollback transaction")
+		if errRollback := c.db.Rollback(ctx); errRollback != nil {
+			c.log.WithContext(ctx).WithParams(map[string]any{"error": errRollback.Error()}).Error("failed to rollback transaction after panic")
 		}
 		panic(p) // re-throw panic after Rollback
 
-	} else if *errIn != nil {
+	} else if *err != nil {
 
 		c.log.WithContext(ctx).Debug("error occurred, rolling back transaction")
 
-		err := c.db.Rollback(ctx)
-		if err != nil {
-			errOut = errors.Wrap(err, "failed to rollback transaction")
+		if errRollback := c.db.Rollback(ctx); errRollback != nil {
+			*err = errors.Wrapf(*err, "%v", errRollback)
 		}
 
 	} else {
 
 		c.log.WithContext(ctx).Debug("committing transaction")
 
-		err := c.db.Commit(ctx)
-		if err != nil {
-			errOut = errors.Wrap(err, "failed to commit transaction")
+		if errCommit := c.db.Commit(ctx); errCommit != nil {
+			*err = errCommit
 		}
 
 	}
-	return
 
 }
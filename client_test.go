@@ -0,0 +1,119 @@
+package fayl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redhajuanda/fayl/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRunner(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{}
+	client.registry.Store(&registry{runners: map[string]query{
+		"user.GetUser":       {sql: "SELECT * FROM users WHERE id = {{ .id }}"},
+		"user.ListUsers":     {sql: "SELECT * FROM users"},
+		"product.GetProduct": {sql: "SELECT * FROM products WHERE id = {{ .id }}"},
+	}})
+
+	t.Run("Success getting an existing runner", func(t *testing.T) {
+		t.Parallel()
+
+		q, err := client.runner("user.GetUser")
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM users WHERE id = {{ .id }}", q.sql)
+		assert.True(t, client.HasRunner("user.GetUser"))
+		assert.Equal(t, []string{"product.GetProduct", "user.GetUser", "user.ListUsers"}, client.Runners())
+	})
+
+	t.Run("Failed getting a misspelled runner", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := client.runner("user.GetUsr")
+		assert.ErrorIs(t, err, ErrRunnerNotFound)
+		assert.EqualError(t, err, "runner user.GetUsr not found, did you mean user.GetUser?")
+		assert.False(t, client.HasRunner("user.GetUsr"))
+	})
+
+	t.Run("Failed getting an unknown runner", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := client.runner("order.CreateOrder")
+
+		var notFound *RunnerNotFoundError
+		assert.ErrorAs(t, err, &notFound)
+		assert.Equal(t, "order.CreateOrder", notFound.RunnerCode)
+		assert.Empty(t, notFound.Suggestion)
+	})
+}
+
+func TestClientRegister(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{}
+	client.registry.Store(&registry{
+		runners: map[string]query{"user.GetUser": {sql: "SELECT * FROM users WHERE id = {{ .id }}"}},
+		parser:  parser.New(),
+	})
+
+	t.Run("Success registering a runner", func(t *testing.T) {
+		err := client.Register("user.CountUsers", "-- +meta\n-- timeout: 1s\n-- +end\nSELECT COUNT(*) FROM users WHERE name = {{ .name }}")
+		assert.NoError(t, err)
+		assert.True(t, client.HasRunner("user.CountUsers"))
+
+		metadata, ok := client.Metadata("user.CountUsers")
+		assert.True(t, ok)
+		assert.Equal(t, time.Second, metadata.Timeout)
+
+		q, err := client.runner("user.CountUsers")
+		assert.NoError(t, err)
+
+		query, args, err := q.tmpl.Execute(context.Background(), map[string]any{"name": "john"}, Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT COUNT(*) FROM users WHERE name = $1", query)
+		assert.Equal(t, []any{"john"}, args)
+	})
+
+	t.Run("Failed registering an existing runner", func(t *testing.T) {
+		err := client.Register("user.GetUser", "SELECT 1")
+		assert.ErrorContains(t, err, "duplicate runner code user.GetUser")
+	})
+
+	t.Run("Failed registering a broken query", func(t *testing.T) {
+		err := client.Register("user.ListUsers", "SELECT * FROM users {{ end }}")
+		assert.Error(t, err)
+		assert.False(t, client.HasRunner("user.ListUsers"))
+	})
+}
+
+func TestClientRunSQL(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{}
+
+	t.Run("Success deriving a runner code per query", func(t *testing.T) {
+		t.Parallel()
+
+		getUser := client.RunSQL("SELECT * FROM users WHERE id = {{ .id }}").(*Runner)
+		listUsers := client.RunSQL("SELECT * FROM users").(*Runner)
+
+		assert.Regexp(t, `^<inline:[0-9a-f]{8}>$`, getUser.runnerCode)
+		assert.NotEqual(t, getUser.runnerCode, listUsers.runnerCode)
+		assert.Equal(t, getUser.runnerCode, client.RunSQL("SELECT * FROM users WHERE id = {{ .id }}").(*Runner).runnerCode)
+	})
+
+	t.Run("Failed registering the params of an inline query", func(t *testing.T) {
+		t.Parallel()
+
+		runner := client.RunSQL("SELECT * FROM users WHERE id = {{ .id }}").(*Runner)
+
+		err := client.RegisterParams(runner.runnerCode, struct {
+			ID int64 `fayl:"id" param:"required"`
+		}{})
+		assert.ErrorContains(t, err, "the params of a RunSQL query are declared in its header")
+	})
+}
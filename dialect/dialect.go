@@ -0,0 +1,96 @@
+package dialect
+
+import "strings"
+
+// Dialect is a SQL dialect.
+// It selects the dialect-specific variant of a query file, e.g. GetUser.postgres.sql over GetUser.sql.
+type Dialect string
+
+const (
+	// Postgres is the dialect of PostgreSQL and compatible databases.
+	Postgres Dialect = "postgres"
+	// MySQL is the dialect of MySQL and MariaDB.
+	MySQL Dialect = "mysql"
+	// SQLite is the dialect of SQLite.
+	SQLite Dialect = "sqlite"
+	// SQLServer is the dialect of Microsoft SQL Server.
+	SQLServer Dialect = "sqlserver"
+	// Oracle is the dialect of Oracle Database.
+	Oracle Dialect = "oracle"
+)
+
+// drivers maps the name of common database/sql drivers to their dialect.
+var drivers = map[string]Dialect{
+	"postgres":         Postgres,
+	"postgresql":       Postgres,
+	"pgx":              Postgres,
+	"pq":               Postgres,
+	"cloudsqlpostgres": Postgres,
+	"mysql":            MySQL,
+	"mariadb":          MySQL,
+	"sqlite":           SQLite,
+	"sqlite3":          SQLite,
+	"sqlserver":        SQLServer,
+	"mssql":            SQLServer,
+	"azuresql":         SQLServer,
+	"oracle":           Oracle,
+	"godror":           Oracle,
+	"oci8":             Oracle,
+}
+
+// FromDriver returns the dialect of the given database/sql driver name.
+// It returns an empty Dialect if the driver is unknown.
+func FromDriver(driverName string) Dialect {
+	return drivers[strings.ToLower(driverName)]
+}
+
+// Parse returns the dialect with the given name, as used in the file name of a query variant.
+// It returns false if the name is not a known dialect.
+func Parse(name string) (Dialect, bool) {
+
+	switch d := Dialect(strings.ToLower(name)); d {
+	case Postgres, MySQL, SQLite, SQLServer, Oracle:
+		return d, true
+	}
+
+	return "", false
+
+}
+
+// QuoteIdentifier quotes the given identifier, so it is interpreted as a table, schema or column name.
+// A dotted identifier such as schema.table is quoted part by part.
+// PostgreSQL, SQLite, Oracle and unknown dialects use double quotes, MySQL uses backticks and SQL Server uses brackets.
+func (d Dialect) QuoteIdentifier(identifier string) string {
+
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		switch d {
+		case MySQL:
+			parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+		case SQLServer:
+			parts[i] = "[" + strings.ReplaceAll(part, "]", "]]") + "]"
+		default:
+			parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+		}
+	}
+
+	return strings.Join(parts, ".")
+
+}
+
+// MaxParams returns the maximum number of bind parameters of a single statement.
+// Unknown dialects use 999, the historical limit of SQLite, which every database supports.
+func (d Dialect) MaxParams() int {
+
+	switch d {
+	case Postgres, MySQL, Oracle:
+		return 65535
+	case SQLite:
+		return 32766
+	case SQLServer:
+		return 2100
+	}
+
+	return 999
+
+}
@@ -0,0 +1,252 @@
+package dialect
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Inline returns a copy of the given query with its placeholders replaced by args written as SQL literals of the dialect,
+// so it can be pasted into a SQL console. It is meant for debugging only: the result must never be executed.
+//
+// The placeholders are either numbered ($1, :1 or @p1) or positional (?). Question marks are only treated as placeholders
+// when the query has no numbered placeholder, so the PostgreSQL JSONB ? operator is kept as is.
+// Placeholders inside string literals, quoted identifiers and comments are ignored.
+// Strings are quoted, times are formatted, nil values are written as NULL and byte slices as hexadecimal.
+func (d Dialect) Inline(query string, args []any) (string, error) {
+
+	tokens := scanPlaceholders(query)
+
+	numbered := false
+	for _, t := range tokens {
+		if t.index > 0 {
+			numbered = true
+			break
+		}
+	}
+
+	var (
+		sb   strings.Builder
+		last int
+		next int
+	)
+
+	for _, t := range tokens {
+
+		i := t.index - 1
+		if !numbered {
+			if t.index != 0 {
+				continue
+			}
+			i = next
+			next++
+		} else if t.index == 0 {
+			continue
+		}
+
+		if i < 0 || i >= len(args) {
+			return "", fmt.Errorf("placeholder %s has no arg", query[t.start:t.end])
+		}
+
+		literal, err := d.Literal(args[i])
+		if err != nil {
+			return "", fmt.Errorf("arg %d: %w", i+1, err)
+		}
+
+		sb.WriteString(query[last:t.start])
+		sb.WriteString(literal)
+		last = t.end
+
+	}
+
+	sb.WriteString(query[last:])
+
+	return sb.String(), nil
+
+}
+
+// placeholderToken is a placeholder found in a query.
+// index is the number of a numbered placeholder, or zero for a question mark.
+type placeholderToken struct {
+	start, end int
+	index      int
+}
+
+// scanPlaceholders returns the placeholders of the given query,
+// skipping string literals, quoted identifiers and comments.
+func scanPlaceholders(query string) []placeholderToken {
+
+	var tokens []placeholderToken
+
+	for i := 0; i < len(query); i++ {
+
+		switch c := query[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			// skip to the closing quote, a doubled quote is an escaped quote
+			for i++; i < len(query); i++ {
+				if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			if j := strings.IndexByte(query[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(query)
+			}
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 3
+			} else {
+				i = len(query)
+			}
+		case c == ':' && strings.HasPrefix(query[i:], "::"):
+			// PostgreSQL cast
+			i++
+		case c == '?':
+			tokens = append(tokens, placeholderToken{start: i, end: i + 1})
+		case c == '$' || c == ':' || (c == '@' && i+1 < len(query) && (query[i+1] == 'p' || query[i+1] == 'P')):
+			start := i
+			j := i + 1
+			if c == '@' {
+				j++
+			}
+			k := j
+			for k < len(query) && query[k] >= '0' && query[k] <= '9' {
+				k++
+			}
+			if k == j {
+				continue
+			}
+			index, _ := strconv.Atoi(query[j:k])
+			tokens = append(tokens, placeholderToken{start: start, end: k, index: index})
+			i = k - 1
+		}
+
+	}
+
+	return tokens
+
+}
+
+// Literal returns the given value written as a SQL literal of the dialect.
+func (d Dialect) Literal(value any) (string, error) {
+
+	if valuer, ok := value.(driver.Valuer); ok {
+		v := reflect.ValueOf(value)
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return "NULL", nil
+		}
+		var err error
+		if value, err = valuer.Value(); err != nil {
+			return "", err
+		}
+	}
+
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "NULL", nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "NULL", nil
+	}
+
+	switch val := v.Interface().(type) {
+	case string:
+		return d.quoteString(val), nil
+	case []byte:
+		return d.bytesLiteral(val), nil
+	case time.Time:
+		return d.timeLiteral(val), nil
+	case bool:
+		return d.boolLiteral(val), nil
+	}
+
+	switch {
+	case v.CanInt():
+		return strconv.FormatInt(v.Int(), 10), nil
+	case v.CanUint():
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case v.CanFloat():
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case v.Kind() == reflect.String:
+		return d.quoteString(v.String()), nil
+	}
+
+	return d.quoteString(fmt.Sprint(v.Interface())), nil
+
+}
+
+// quoteString quotes the given string, doubling its quotes.
+// MySQL also treats backslashes as escape characters, so they are doubled as well.
+func (d Dialect) quoteString(s string) string {
+
+	s = strings.ReplaceAll(s, "'", "''")
+	if d == MySQL {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+
+	return "'" + s + "'"
+
+}
+
+// bytesLiteral returns the hexadecimal literal of the given bytes.
+func (d Dialect) bytesLiteral(b []byte) string {
+
+	h := strings.ToUpper(hex.EncodeToString(b))
+
+	switch d {
+	case Postgres:
+		return `'\x` + h + "'"
+	case SQLServer:
+		return "0x" + h
+	case Oracle:
+		return "HEXTORAW('" + h + "')"
+	}
+
+	return "X'" + h + "'"
+
+}
+
+// timeLiteral returns the literal of the given time.
+func (d Dialect) timeLiteral(t time.Time) string {
+
+	switch d {
+	case MySQL:
+		return "'" + t.Format("2006-01-02 15:04:05.999999") + "'"
+	case SQLServer:
+		return "'" + t.Format("2006-01-02T15:04:05.9999999-07:00") + "'"
+	case Oracle:
+		return "TIMESTAMP '" + t.Format("2006-01-02 15:04:05.999999999 -07:00") + "'"
+	}
+
+	return "'" + t.Format("2006-01-02 15:04:05.999999-07:00") + "'"
+
+}
+
+// boolLiteral returns the literal of the given boolean.
+// SQL Server and Oracle have no boolean literal, they use 1 and 0.
+func (d Dialect) boolLiteral(b bool) string {
+
+	switch d {
+	case SQLServer, Oracle:
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+
+	return strings.ToUpper(strconv.FormatBool(b))
+
+}
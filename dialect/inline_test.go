@@ -0,0 +1,69 @@
+package dialect
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInline(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name          string
+		dialect       Dialect
+		query         string
+		args          []any
+		expectedQuery string
+	}{
+		{
+			name:          "Postgres",
+			dialect:       Postgres,
+			query:         `SELECT * FROM users WHERE name = $1 AND data ? 'key' AND note = '$2' AND created_at > $2::timestamptz AND avatar = $3 AND deleted_at IS $4`,
+			args:          []any{"O'Brien", createdAt, []byte{0xde, 0xad}, nil},
+			expectedQuery: `SELECT * FROM users WHERE name = 'O''Brien' AND data ? 'key' AND note = '$2' AND created_at > '2024-05-01 10:30:00+00:00'::timestamptz AND avatar = '\xDEAD' AND deleted_at IS NULL`,
+		},
+		{
+			name:          "MySQL",
+			dialect:       MySQL,
+			query:         "SELECT * FROM users WHERE name = ? AND path = ? AND active = ? /* ? */ AND age > ?",
+			args:          []any{"john", `C:\users`, true, 18},
+			expectedQuery: "SELECT * FROM users WHERE name = 'john' AND path = 'C:\\\\users' AND active = TRUE /* ? */ AND age > 18",
+		},
+		{
+			name:          "SQLServer",
+			dialect:       SQLServer,
+			query:         "SELECT * FROM users WHERE active = @p1 AND avatar = @p2 AND score > @p3",
+			args:          []any{false, []byte{0x01}, 1.5},
+			expectedQuery: "SELECT * FROM users WHERE active = 0 AND avatar = 0x01 AND score > 1.5",
+		},
+		{
+			name:          "Oracle",
+			dialect:       Oracle,
+			query:         "SELECT * FROM users WHERE email = :1 AND created_at > :2",
+			args:          []any{sql.NullString{}, createdAt},
+			expectedQuery: "SELECT * FROM users WHERE email = NULL AND created_at > TIMESTAMP '2024-05-01 10:30:00 +00:00'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run("Success inlining args with "+tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := tc.dialect.Inline(tc.query, tc.args)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedQuery, query)
+		})
+	}
+
+	t.Run("Failed inlining a placeholder without arg", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := Postgres.Inline("SELECT * FROM users WHERE id = $2", []any{1})
+		assert.EqualError(t, err, "placeholder $2 has no arg")
+	})
+}
@@ -0,0 +1,97 @@
+package fayl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrRunnerNotFound is returned when a runner code does not exist, use errors.Is to check for it.
+	// The returned error is a *RunnerNotFoundError that carries the code and a suggestion.
+	ErrRunnerNotFound = errors.New("runner not found")
+	// ErrReadOnlyRunner is returned when Exec is called on a runner whose header declares it read-only.
+	ErrReadOnlyRunner = errors.New("runner is read-only")
+	// ErrCardinalityMismatch is returned when a runner is scanned in a way that contradicts the cardinality declared in its header.
+	ErrCardinalityMismatch = errors.New("runner cardinality mismatch")
+)
+
+// RunnerNotFoundError is returned when a runner code does not exist.
+type RunnerNotFoundError struct {
+	// RunnerCode is the code that was not found.
+	RunnerCode string
+	// Suggestion is the closest loaded runner code, it is empty if none is close enough.
+	Suggestion string
+}
+
+// Error returns the error message, including the suggestion if any.
+func (e *RunnerNotFoundError) Error() string {
+
+	if e.Suggestion != "" {
+		return fmt.Sprintf("runner %s not found, did you mean %s?", e.RunnerCode, e.Suggestion)
+	}
+	return fmt.Sprintf("runner %s not found", e.RunnerCode)
+
+}
+
+// Is reports whether target is ErrRunnerNotFound.
+func (e *RunnerNotFoundError) Is(target error) bool {
+	return target == ErrRunnerNotFound
+}
+
+// ParamsError is returned in strict mode when the params of a runner do not match the params referenced by its template.
+type ParamsError struct {
+	RunnerCode string
+	// Missing are the params referenced by the template but not set on the runner.
+	Missing []string
+	// Unused are the params set on the runner but not referenced by the template.
+	Unused []string
+}
+
+// Error returns the error message listing the missing and unused params.
+func (e *ParamsError) Error() string {
+
+	var msgs []string
+	if len(e.Missing) > 0 {
+		msgs = append(msgs, "missing params: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Unused) > 0 {
+		msgs = append(msgs, "unused params: "+strings.Join(e.Unused, ", "))
+	}
+
+	return fmt.Sprintf("runner %s: %s", e.RunnerCode, strings.Join(msgs, "; "))
+
+}
+
+// TemplateError is a query template that failed to compile.
+type TemplateError struct {
+	RunnerCode string
+	Err        error
+}
+
+// Error returns the error message of the template.
+func (e TemplateError) Error() string {
+	return fmt.Sprintf("%s: %v", e.RunnerCode, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// TemplateErrors is returned by Init when one or more query templates fail to compile.
+// It lists every broken template instead of stopping at the first one.
+type TemplateErrors []TemplateError
+
+// Error returns the error messages of all the templates.
+func (e TemplateErrors) Error() string {
+
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("failed to compile %d query templates: %s", len(e), strings.Join(msgs, "; "))
+
+}
@@ -0,0 +1,102 @@
+package fayl
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/redhajuanda/fayl/parser"
+	"github.com/redhajuanda/perkakas/logger"
+
+	"github.com/VauntDev/tqla"
+	"github.com/jmoiron/sqlx"
+)
+
+// fakeConnector is a database/sql connector whose statements all return the same rows,
+// to test the execution and the scanning without a database.
+type fakeConnector struct {
+	columns   []string
+	rows      [][]driver.Value
+	commitErr error
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{c}, nil }
+func (c *fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ connector *fakeConnector }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return &fakeStmt{c.connector}, nil }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{c.connector}, nil }
+
+type fakeTx struct{ connector *fakeConnector }
+
+func (tx fakeTx) Commit() error { return tx.connector.commitErr }
+func (fakeTx) Rollback() error  { return nil }
+
+type fakeStmt struct{ connector *fakeConnector }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(len(s.connector.rows)), nil
+}
+
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{columns: s.connector.columns, rows: s.connector.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	i       int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+
+	return nil
+
+}
+
+// newFakeClient returns a client whose queries all return the given rows.
+func newFakeClient(t *testing.T, columns []string, rows ...[]driver.Value) *Client {
+
+	t.Helper()
+
+	return newFakeConnectorClient(t, &fakeConnector{columns: columns, rows: rows})
+
+}
+
+// newFakeConnectorClient returns a client connected to the given fake connector.
+func newFakeConnectorClient(t *testing.T, connector *fakeConnector) *Client {
+
+	t.Helper()
+
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+
+	client := &Client{
+		db:          &DB{DB: sqlx.NewDb(db, "postgres")},
+		placeholder: tqla.Dollar,
+		log:         logger.New("test"),
+	}
+	client.registry.Store(&registry{
+		runners: map[string]query{},
+		parser:  parser.New(),
+	})
+
+	return client
+
+}
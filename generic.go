@@ -0,0 +1,139 @@
+package fayl
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// One runs the query of the given runner and returns its single row as a T.
+// T is a struct scanned like ScanStruct, a map[string]any scanned like ScanMap,
+// or the type of a single column scanned like ScanValue, e.g. int64 or time.Time.
+// It returns sql.ErrNoRows if the query returns no row.
+// The runner can come from Client.Run or Tx.Run, e.g. fayl.One[User](ctx, client.Run("user.GetUser").WithParam("id", id)).
+func One[T any](ctx context.Context, runner Runnerer) (T, error) {
+
+	var out T
+
+	switch dest := any(&out).(type) {
+	case *map[string]any:
+		*dest = make(map[string]any)
+		runner = runner.ScanMap(*dest)
+	default:
+		if scansFields(reflect.TypeFor[T]()) {
+			runner = runner.ScanStruct(&out)
+		} else {
+			runner = runner.ScanValue(&out)
+		}
+	}
+
+	if err := runner.Query(ctx); err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return out, nil
+
+}
+
+// All runs the query of the given runner and returns its rows as a slice of T.
+// T is a struct scanned like ScanStructs, a map[string]any scanned like ScanMaps,
+// or the type of a single column scanned like ScanValues, e.g. int64 or uuid.UUID.
+// It returns an empty slice if the query returns no row.
+// The runner can come from Client.Run or Tx.Run, e.g. fayl.All[User](ctx, client.Run("user.ListUsers")).
+func All[T any](ctx context.Context, runner Runnerer) ([]T, error) {
+
+	out := make([]T, 0)
+
+	switch dest := any(&out).(type) {
+	case *[]map[string]any:
+		runner = runner.ScanMaps(dest)
+	default:
+		if scansFields(reflect.TypeFor[T]()) {
+			runner = runner.ScanStructs(&out)
+		} else {
+			runner = runner.ScanValues(&out)
+		}
+	}
+
+	if err := runner.Query(ctx); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+
+}
+
+// Exec executes the query of the given runner and returns its result.
+// The runner can come from Client.Run or Tx.Run, e.g. fayl.Exec(ctx, tx.Run("user.DeleteUser").WithParam("id", id)).
+func Exec(ctx context.Context, runner Runnerer) (*ResultExec, error) {
+
+	return runner.Exec(ctx)
+
+}
+
+// Iter runs the query of the given runner and streams its rows as T, one row at a time,
+// so a large result set is never held in memory. T is scanned like in All.
+// The rows are closed when the loop ends, breaks or when ctx is canceled.
+// An error ends the iteration, it is yielded with the zero value of T.
+//
+//	for user, err := range fayl.Iter[User](ctx, client.Run("user.ListUsers")) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func Iter[T any](ctx context.Context, runner Runnerer) iter.Seq2[T, error] {
+
+	return func(yield func(T, error) bool) {
+
+		err := runner.ScanFunc(func(row *Row) error {
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var out T
+			if err := row.Scan(&out); err != nil {
+				return err
+			}
+
+			if !yield(out, nil) {
+				return errStopIteration
+			}
+
+			return nil
+
+		}).Query(ctx)
+		if err != nil && !errors.Is(err, errStopIteration) {
+			var zero T
+			yield(zero, err)
+		}
+
+	}
+
+}
+
+// errStopIteration stops the scanning of the rows when the loop over Iter breaks.
+var errStopIteration = errors.New("iteration stopped")
+
+var (
+	sqlScannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// scansFields reports whether t is a struct scanned column by column into its fields.
+// Structs scanned as a single value, such as time.Time or sql.NullString, are not.
+func scansFields(t reflect.Type) bool {
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	return t != timeType && !reflect.PointerTo(t).Implements(sqlScannerType)
+
+}
@@ -0,0 +1,211 @@
+package fayl
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type genericUser struct {
+	ID   int64  `fayl:"id"`
+	Name string `fayl:"name"`
+}
+
+func TestGeneric(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success getting a struct", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, []driver.Value{int64(1), "john"})
+		require.NoError(t, client.Register("user.GetUser", "SELECT id, name FROM users WHERE id = {{ .id }}"))
+
+		user, err := One[genericUser](context.Background(), client.Run("user.GetUser").WithParam("id", 1))
+		assert.NoError(t, err)
+		assert.Equal(t, genericUser{ID: 1, Name: "john"}, user)
+	})
+
+	t.Run("Success getting all rows as structs, maps and values", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, []driver.Value{int64(1), "john"}, []driver.Value{int64(2), "jane"})
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name FROM users"))
+
+		users, err := All[genericUser](context.Background(), client.Run("user.ListUsers"))
+		assert.NoError(t, err)
+		assert.Equal(t, []genericUser{{ID: 1, Name: "john"}, {ID: 2, Name: "jane"}}, users)
+
+		maps, err := All[map[string]any](context.Background(), client.Run("user.ListUsers"))
+		assert.NoError(t, err)
+		assert.Len(t, maps, 2)
+		assert.Equal(t, "jane", maps[1]["name"])
+
+		client = newFakeClient(t, []string{"id"}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+		require.NoError(t, client.Register("user.ListUserIDs", "SELECT id FROM users"))
+
+		ids, err := All[int64](context.Background(), client.Run("user.ListUserIDs"))
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{1, 2}, ids)
+	})
+
+	t.Run("Success running in a transaction", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"total"}, []driver.Value{int64(2)})
+		require.NoError(t, client.Register("user.CountUsers", "SELECT COUNT(*) AS total FROM users"))
+		require.NoError(t, client.Register("user.DeleteUsers", "DELETE FROM users"))
+
+		_, err := client.WithTransaction(context.Background(), func(ctx context.Context, tx *Tx) (any, error) {
+
+			total, err := One[int64](ctx, tx.Run("user.CountUsers"))
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), total)
+
+			result, err := Exec(ctx, tx.Run("user.DeleteUsers"))
+			assert.NoError(t, err)
+
+			affected, err := result.RowsAffected()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), affected)
+
+			return nil, nil
+
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Failed getting a missing row", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"})
+		require.NoError(t, client.Register("user.GetUser", "SELECT id, name FROM users WHERE id = {{ .id }}"))
+
+		user, err := One[genericUser](context.Background(), client.Run("user.GetUser").WithParam("id", 1))
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Zero(t, user)
+
+		row, err := One[map[string]any](context.Background(), client.Run("user.GetUser").WithParam("id", 1))
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.Nil(t, row)
+	})
+}
+
+func TestIter(t *testing.T) {
+	t.Parallel()
+
+	rows := [][]driver.Value{{int64(1), "john"}, {int64(2), "jane"}, {int64(3), "jack"}}
+
+	t.Run("Success streaming every row", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, rows...)
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name FROM users"))
+
+		var users []genericUser
+		for user, err := range Iter[genericUser](context.Background(), client.Run("user.ListUsers")) {
+			require.NoError(t, err)
+			users = append(users, user)
+		}
+		assert.Equal(t, []genericUser{{ID: 1, Name: "john"}, {ID: 2, Name: "jane"}, {ID: 3, Name: "jack"}}, users)
+	})
+
+	t.Run("Success closing the rows on break", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, rows...)
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name FROM users"))
+
+		var names []string
+		for row, err := range Iter[map[string]any](context.Background(), client.Run("user.ListUsers")) {
+			require.NoError(t, err)
+			names = append(names, row["name"].(string))
+			break
+		}
+		assert.Equal(t, []string{"john"}, names)
+		assert.Zero(t, client.db.Stats().InUse)
+	})
+
+	t.Run("Success streaming rows with a callback", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, rows...)
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name FROM users"))
+
+		var ids []int64
+		err := client.Run("user.ListUsers").ScanFunc(func(row *Row) error {
+
+			assert.Equal(t, []string{"id", "name"}, row.Columns())
+
+			var user genericUser
+			if err := row.Scan(&user); err != nil {
+				return err
+			}
+			ids = append(ids, user.ID)
+
+			return nil
+
+		}).Query(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{1, 2, 3}, ids)
+	})
+
+	t.Run("Success streaming the row of a single row runner", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, rows[0])
+		require.NoError(t, client.Register("user.GetUser", "-- +meta\n-- cardinality: one\n-- +end\nSELECT id, name FROM users WHERE id = {{ .id }}"))
+
+		var users []genericUser
+		for user, err := range Iter[genericUser](context.Background(), client.Run("user.GetUser").WithParam("id", 1)) {
+			require.NoError(t, err)
+			users = append(users, user)
+		}
+		assert.Equal(t, []genericUser{{ID: 1, Name: "john"}}, users)
+
+		err := client.Run("user.GetUser").WithParam("id", 1).ScanMaps(&[]map[string]any{}).Query(context.Background())
+		assert.ErrorIs(t, err, ErrCardinalityMismatch)
+		assert.ErrorContains(t, err, "use ScanMap, ScanStruct, ScanValue or ScanFunc instead")
+	})
+
+	t.Run("Failed streaming rows after the context is canceled", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, rows...)
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name FROM users"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var (
+			ids  []int64
+			errs []error
+		)
+		for user, err := range Iter[genericUser](ctx, client.Run("user.ListUsers")) {
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			ids = append(ids, user.ID)
+			cancel()
+		}
+		assert.Equal(t, []int64{1}, ids)
+		require.Len(t, errs, 1)
+		assert.ErrorIs(t, errs[0], context.Canceled)
+		assert.Zero(t, client.db.Stats().InUse)
+	})
+
+	t.Run("Failed streaming a value from several columns", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, rows...)
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name FROM users"))
+
+		for _, err := range Iter[int64](context.Background(), client.Run("user.ListUsers")) {
+			assert.EqualError(t, err, "expected 1 column, got 2")
+		}
+	})
+}
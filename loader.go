@@ -0,0 +1,373 @@
+package fayl
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/redhajuanda/fayl/dialect"
+	"github.com/redhajuanda/fayl/parser"
+
+	"github.com/pkg/errors"
+)
+
+// nameMarker matches a "-- name: <name>" line that starts a named query inside a .sql file.
+var nameMarker = regexp.MustCompile(`(?m)^[ \t]*--[ \t]*name[ \t]*:[ \t]*([A-Za-z0-9_.]+)[ \t]*\r?$`)
+
+// partialsDir is the name of the directories that contain partials.
+const partialsDir = "_partials"
+
+// query is a SQL query template loaded from a query file, along with the metadata declared in its header.
+type query struct {
+	sql      string
+	metadata Metadata
+	tmpl     *parser.Template
+}
+
+// namedQuery is a query defined inside a .sql file.
+// name is empty when the file holds a single query without name marker.
+type namedQuery struct {
+	name  string
+	query string
+}
+
+// querySource is a resolved QueryRoot: a directory of SQL query files inside a file system.
+type querySource struct {
+	fsys     fs.FS
+	root     string
+	prefix   string
+	location string
+}
+
+// newQuerySource resolves the given QueryRoot into a querySource.
+func newQuerySource(qr QueryRoot) querySource {
+
+	src := querySource{
+		fsys:   qr.FS,
+		root:   path.Clean(qr.FSRoot),
+		prefix: strings.TrimSuffix(qr.Prefix, "."),
+	}
+	if src.prefix != "" {
+		src.prefix += "."
+	}
+
+	// Location is only read when no FS is set, so only then it locates the files on disk
+	if src.fsys == nil {
+		src.fsys, src.root, src.location = os.DirFS(qr.Location), ".", qr.Location
+	}
+
+	return src
+
+}
+
+// describe returns a human readable location of the given file, used in error messages.
+func (s querySource) describe(filePath string) string {
+
+	if s.location != "" {
+		return filepath.Join(s.location, filepath.FromSlash(filePath))
+	}
+	return filePath
+
+}
+
+// loadQueries loads every .sql file of the given sources.
+// It returns the queries keyed by their runner code, and the partials keyed by their name.
+// Files inside a _partials directory are partials: they are not runnable, but every query can include them with {{ template "name" . }}.
+// The name of a partial is derived like a runner code without the _partials directory, e.g. user/_partials/Columns.sql becomes user.Columns.
+// A file whose name ends with a dialect, e.g. GetUser.postgres.sql, is a variant of GetUser.sql for that dialect:
+// the variant matching d replaces the generic query, and the variants of other dialects are ignored.
+// It fails when two files produce the same runner code or partial name instead of silently overwriting one of them.
+func loadQueries(sources []querySource, d dialect.Dialect) (map[string]query, map[string]string, error) {
+
+	var (
+		// Initialize the runners map to store SQL queries
+		runners = make(map[string]query)
+		// Initialize the partials map to store SQL partials
+		partials = make(map[string]string)
+		// origins stores the file each runner code was loaded from
+		origins = make(map[string]string)
+		// variants stores the runner codes whose variant for the dialect has been loaded
+		variants = make(map[string]bool)
+	)
+
+	for _, src := range sources {
+
+		err := walkQueries(src, func(fileCode, filePath string, content []byte) error {
+
+			fileCode, variant := variantName(fileCode, filePath)
+			fileCode, partial := partialName(fileCode)
+
+			// Skip the variants of other dialects
+			if variant != "" && variant != d {
+				return nil
+			}
+
+			queries, err := splitQueries(string(content))
+			if err != nil {
+				return fmt.Errorf("error parsing file %s: %v", src.describe(filePath), err)
+			}
+
+			for _, q := range queries {
+
+				code := fileCode
+				if q.name != "" {
+					code += "." + q.name
+				}
+
+				key := code
+				if variant != "" {
+					key += "." + string(variant)
+				}
+
+				if origin, ok := origins[key]; ok {
+					return fmt.Errorf("duplicate runner code %s: defined in %s and %s", key, origin, src.describe(filePath))
+				}
+
+				// Parse the metadata declared in the header of the query
+				metadata, sql, err := parseMetadata(q.query)
+				if err != nil {
+					return fmt.Errorf("error parsing runner %s in %s: %v", code, src.describe(filePath), err)
+				}
+
+				origins[key] = src.describe(filePath)
+
+				// The generic query never replaces the variant for the dialect
+				if variant == "" && variants[code] {
+					continue
+				}
+				if variant != "" {
+					variants[code] = true
+				}
+
+				// Store the SQL partial in the partials map, its metadata is meaningless
+				if partial {
+					partials[code] = sql
+					continue
+				}
+
+				// Store the SQL query in the runners map
+				runners[code] = query{sql: sql, metadata: metadata}
+
+			}
+
+			return nil
+
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+	}
+
+	return runners, partials, nil
+
+}
+
+// variantName reports whether the query file with the given code and path is a dialect variant,
+// and returns the code without the dialect, e.g. user.GetUser.postgres becomes user.GetUser.
+// Only a file named <base>.<dialect>.sql, with a non-empty base and a lowercase dialect, is a variant,
+// so a file such as export/mysql.sql keeps its runner code export.mysql.
+func variantName(fileCode, filePath string) (string, dialect.Dialect) {
+
+	name := strings.TrimSuffix(path.Base(filePath), path.Ext(filePath))
+
+	i := strings.LastIndex(name, ".")
+	if i <= 0 {
+		return fileCode, ""
+	}
+
+	d, ok := dialect.Parse(name[i+1:])
+	if !ok || string(d) != name[i+1:] {
+		return fileCode, ""
+	}
+
+	return strings.TrimSuffix(fileCode, name[i:]), d
+
+}
+
+// partialName reports whether the query file with the given code is inside a _partials directory,
+// and returns the code without the _partials directories.
+func partialName(fileCode string) (string, bool) {
+
+	var (
+		parts   = strings.Split(fileCode, ".")
+		name    = make([]string, 0, len(parts))
+		partial bool
+	)
+
+	for i, part := range parts {
+		if part == partialsDir && i < len(parts)-1 {
+			partial = true
+			continue
+		}
+		name = append(name, part)
+	}
+
+	return strings.Join(name, "."), partial
+
+}
+
+// walkQueries walks through the root of src and its subdirectories and calls fn for every .sql file.
+// The runner code is the prefix of src followed by the file path relative to its root,
+// without its extension and with the path separators replaced by dots, e.g. user/GetUser.sql becomes user.GetUser.
+func walkQueries(src querySource, fn func(code, filePath string, content []byte) error) error {
+
+	// Check if root exists
+	if _, err := fs.Stat(src.fsys, src.root); err != nil {
+		return fmt.Errorf("query location does not exist: %s", src.describe(src.root))
+	}
+
+	err := fs.WalkDir(src.fsys, src.root, func(filePath string, d fs.DirEntry, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		// Skip directories
+		if d.IsDir() {
+			return nil
+		}
+
+		// Process only .sql files
+		if strings.ToLower(path.Ext(filePath)) != ".sql" {
+			return nil
+		}
+
+		// Read the SQL file
+		content, err := fs.ReadFile(src.fsys, filePath)
+		if err != nil {
+			return fmt.Errorf("error reading file %s: %v", src.describe(filePath), err)
+		}
+
+		return fn(src.prefix+runnerCode(src.root, filePath), filePath, content)
+
+	})
+	if err != nil {
+		return fmt.Errorf("error walking through query location: %v", err)
+	}
+
+	return nil
+
+}
+
+// runnerCode derives the runner code from the path of a query file relative to root.
+// fs.FS paths always use forward slashes, so the result is the same on every platform.
+func runnerCode(root, filePath string) string {
+
+	relPath := strings.TrimPrefix(filePath, root)
+	if root == "." {
+		relPath = filePath
+	}
+	relPath = strings.TrimPrefix(relPath, "/")
+
+	// Create the key by removing the extension and replacing path separators with dots
+	key := strings.TrimSuffix(relPath, path.Ext(relPath))
+	return strings.ReplaceAll(key, "/", ".")
+
+}
+
+// splitQueries splits the content of a .sql file into its named queries.
+// A file without "-- name: <name>" marker holds a single query, which keeps the one-query-per-file layout working.
+// Otherwise every marker starts a new query that ends at the next marker,
+// and only blank lines and comments are allowed before the first marker.
+func splitQueries(content string) ([]namedQuery, error) {
+
+	markers := nameMarker.FindAllStringSubmatchIndex(content, -1)
+	if len(markers) == 0 {
+		return []namedQuery{{query: content}}, nil
+	}
+
+	// Ensure nothing but comments precedes the first named query
+	for _, line := range strings.Split(content[:markers[0][0]], "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return nil, errors.New("query found before the first name marker")
+		}
+	}
+
+	queries := make([]namedQuery, 0, len(markers))
+	for i, m := range markers {
+
+		end := len(content)
+		if i+1 < len(markers) {
+			end = markers[i+1][0]
+		}
+
+		q := namedQuery{
+			name:  content[m[2]:m[3]],
+			query: strings.TrimSpace(content[m[1]:end]),
+		}
+		if q.query == "" {
+			return nil, errors.Errorf("named query %s is empty", q.name)
+		}
+
+		queries = append(queries, q)
+
+	}
+
+	return queries, nil
+
+}
+
+// compileQueries compiles the template of every query, so a broken template is reported at Init rather than when it is run.
+// It returns a TemplateErrors listing every template that failed to compile.
+func compileQueries(p parser.Parser, runners map[string]query) error {
+
+	var errs TemplateErrors
+
+	for code, q := range runners {
+
+		tmpl, err := compileTemplate(p, code, q.sql)
+		if err != nil {
+			errs = append(errs, TemplateError{RunnerCode: code, Err: err})
+			continue
+		}
+
+		q.tmpl = tmpl
+		runners[code] = q
+
+	}
+
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].RunnerCode < errs[j].RunnerCode })
+		return errs
+	}
+
+	return nil
+
+}
+
+// compileQuery parses the metadata header of the given SQL query and compiles its template.
+func compileQuery(p parser.Parser, runnerCode, sql string) (query, error) {
+
+	metadata, sql, err := parseMetadata(sql)
+	if err != nil {
+		return query{}, errors.Wrapf(err, "failed to parse runner %s", runnerCode)
+	}
+
+	tmpl, err := compileTemplate(p, runnerCode, sql)
+	if err != nil {
+		return query{}, errors.Wrapf(err, "failed to compile runner %s", runnerCode)
+	}
+
+	return query{sql: sql, metadata: metadata, tmpl: tmpl}, nil
+
+}
+
+// compileTemplate compiles the given query template if the parser is a parser.Compiler.
+// The templates of other parsers are parsed on every run, so it returns a nil template for them.
+func compileTemplate(p parser.Parser, runnerCode, sql string) (*parser.Template, error) {
+
+	c, ok := p.(parser.Compiler)
+	if !ok {
+		return nil, nil
+	}
+
+	return c.Compile(runnerCode, sql)
+
+}
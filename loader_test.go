@@ -0,0 +1,276 @@
+package fayl
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/redhajuanda/fayl/dialect"
+	"github.com/redhajuanda/fayl/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadQueries(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"queries/user/GetUser.sql":       {Data: []byte("SELECT * FROM users WHERE id = {{ .id }}")},
+		"queries/user/ListUsers.SQL":     {Data: []byte("SELECT * FROM users")},
+		"queries/product/GetProduct.sql": {Data: []byte("SELECT * FROM products WHERE id = {{ .id }}")},
+		"queries/README.md":              {Data: []byte("not a query")},
+	}
+
+	t.Run("Success loading from a sub directory", func(t *testing.T) {
+		t.Parallel()
+
+		runners, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys, FSRoot: "queries/"})}, "")
+		assert.NoError(t, err)
+		assert.Len(t, runners, 3)
+		assert.Equal(t, "SELECT * FROM users WHERE id = {{ .id }}", runners["user.GetUser"].sql)
+		assert.Equal(t, "SELECT * FROM users", runners["user.ListUsers"].sql)
+		assert.Contains(t, runners, "product.GetProduct")
+	})
+
+	t.Run("Success loading from the root", func(t *testing.T) {
+		t.Parallel()
+
+		runners, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+		assert.NoError(t, err)
+		assert.Contains(t, runners, "queries.user.GetUser")
+	})
+
+	t.Run("Success loading several roots with prefixes", func(t *testing.T) {
+		t.Parallel()
+
+		runners, _, err := loadQueries([]querySource{
+			newQuerySource(QueryRoot{FS: fsys, FSRoot: "queries/user", Prefix: "identity"}),
+			newQuerySource(QueryRoot{FS: fsys, FSRoot: "queries/product", Prefix: "catalog."}),
+		}, "")
+		assert.NoError(t, err)
+		assert.Len(t, runners, 3)
+		assert.Contains(t, runners, "identity.GetUser")
+		assert.Contains(t, runners, "identity.ListUsers")
+		assert.Contains(t, runners, "catalog.GetProduct")
+	})
+
+	t.Run("Failed loading roots with the same runner code", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := loadQueries([]querySource{
+			newQuerySource(QueryRoot{FS: fsys, FSRoot: "queries"}),
+			newQuerySource(QueryRoot{FS: fsys, FSRoot: "queries/user", Prefix: "user"}),
+		}, "")
+		assert.ErrorContains(t, err, "duplicate runner code user.GetUser")
+	})
+
+	t.Run("Success loading several named queries per file", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"user.sql":               {Data: []byte("-- queries of the users table\n\n-- name: GetUser\nSELECT * FROM users WHERE id = {{ .id }};\n\n-- name: ListUsers\nSELECT * FROM users;\n")},
+			"product/GetProduct.sql": {Data: []byte("SELECT * FROM products WHERE id = {{ .id }}")},
+		}
+
+		runners, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+		assert.NoError(t, err)
+		assert.Len(t, runners, 3)
+		assert.Equal(t, "SELECT * FROM users WHERE id = {{ .id }};", runners["user.GetUser"].sql)
+		assert.Equal(t, "SELECT * FROM users;", runners["user.ListUsers"].sql)
+		assert.Contains(t, runners, "product.GetProduct")
+	})
+
+	t.Run("Success loading the metadata of named queries", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"user.sql": {Data: []byte("-- name: GetUser\n-- +meta\n-- description: Get a user by its id\n-- timeout: 5s\n-- read-only: true\n-- cardinality: one\n-- tags: user, profile\n-- cache-ttl: 1m\n-- deprecated: use user.GetUserV2 instead\n-- +end\nSELECT * FROM users WHERE id = {{ .id }};\n")},
+		}
+
+		runners, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+		assert.NoError(t, err)
+		// the lines of the header are blanked, so the line numbers of the template match the file
+		assert.Equal(t, strings.Repeat("\n", 9)+"SELECT * FROM users WHERE id = {{ .id }};", runners["user.GetUser"].sql)
+		assert.Equal(t, Metadata{
+			Description:     "Get a user by its id",
+			Timeout:         5 * time.Second,
+			ReadOnly:        true,
+			Cardinality:     CardinalityOne,
+			Tags:            []string{"user", "profile"},
+			CacheTTL:        time.Minute,
+			Deprecated:      true,
+			DeprecationNote: "use user.GetUserV2 instead",
+		}, runners["user.GetUser"].metadata)
+	})
+
+	t.Run("Success loading partials apart from runners", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"_partials/tenant.sql":       {Data: []byte("tenant_id = {{ .tenant_id }}")},
+			"user/_partials/columns.sql": {Data: []byte("id, name, email")},
+			"user/GetUser.sql":           {Data: []byte(`SELECT {{ template "user.columns" . }} FROM users WHERE {{ template "tenant" . }}`)},
+		}
+
+		runners, partials, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys, Prefix: "identity"})}, "")
+		assert.NoError(t, err)
+		assert.Len(t, runners, 1)
+		assert.Contains(t, runners, "identity.user.GetUser")
+		assert.Equal(t, map[string]string{
+			"identity.tenant":       "tenant_id = {{ .tenant_id }}",
+			"identity.user.columns": "id, name, email",
+		}, partials)
+	})
+
+	t.Run("Success loading the variants of the dialect", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"user/GetUser.sql":          {Data: []byte("SELECT * FROM users WHERE id = {{ .id }}")},
+			"user/GetUser.postgres.sql": {Data: []byte("SELECT * FROM users WHERE id = {{ .id }}::uuid")},
+			"user/GetUser.mysql.sql":    {Data: []byte("SELECT * FROM users WHERE id = UUID_TO_BIN({{ .id }})")},
+			"user/ListUsers.mysql.sql":  {Data: []byte("SELECT * FROM users LIMIT 100")},
+		}
+		sources := []querySource{newQuerySource(QueryRoot{FS: fsys})}
+
+		runners, _, err := loadQueries(sources, dialect.Postgres)
+		assert.NoError(t, err)
+		assert.Len(t, runners, 1)
+		assert.Equal(t, "SELECT * FROM users WHERE id = {{ .id }}::uuid", runners["user.GetUser"].sql)
+
+		runners, _, err = loadQueries(sources, dialect.MySQL)
+		assert.NoError(t, err)
+		assert.Len(t, runners, 2)
+		assert.Equal(t, "SELECT * FROM users WHERE id = UUID_TO_BIN({{ .id }})", runners["user.GetUser"].sql)
+		assert.Equal(t, "SELECT * FROM users LIMIT 100", runners["user.ListUsers"].sql)
+
+		runners, _, err = loadQueries(sources, dialect.SQLite)
+		assert.NoError(t, err)
+		assert.Len(t, runners, 1)
+		assert.Equal(t, "SELECT * FROM users WHERE id = {{ .id }}", runners["user.GetUser"].sql)
+	})
+
+	t.Run("Success loading a query with plain leading comments", func(t *testing.T) {
+		t.Parallel()
+
+		query := "-- Timeout: see ticket 123\n-- readonly: yes, for reporting\nSELECT * FROM users"
+		fsys := fstest.MapFS{
+			"report/ListUsers.sql": {Data: []byte(query)},
+		}
+
+		runners, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+		assert.NoError(t, err)
+		assert.Equal(t, query, runners["report.ListUsers"].sql)
+		assert.Zero(t, runners["report.ListUsers"].metadata)
+	})
+
+	t.Run("Success loading a file named after a dialect as a runner", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"export/mysql.sql":     {Data: []byte("SELECT * FROM mysql_users")},
+			"reports/Postgres.sql": {Data: []byte("SELECT * FROM postgres_users")},
+		}
+		sources := []querySource{newQuerySource(QueryRoot{FS: fsys})}
+
+		for _, d := range []dialect.Dialect{dialect.MySQL, dialect.Postgres, dialect.SQLite} {
+			runners, _, err := loadQueries(sources, d)
+			assert.NoError(t, err)
+			assert.Equal(t, "SELECT * FROM mysql_users", runners["export.mysql"].sql)
+			assert.Equal(t, "SELECT * FROM postgres_users", runners["reports.Postgres"].sql)
+			assert.Len(t, runners, 2)
+		}
+	})
+
+	t.Run("Failed loading an invalid header", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"user/GetUser.sql": {Data: []byte("-- +meta\n-- timeout: soon\n-- +end\nSELECT * FROM users WHERE id = {{ .id }}")},
+		}
+
+		_, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+		assert.ErrorContains(t, err, "invalid timeout header")
+
+		fsys = fstest.MapFS{
+			"user/GetUser.sql": {Data: []byte("-- +meta\n-- timeuot: 5s\n-- +end\nSELECT * FROM users WHERE id = {{ .id }}")},
+		}
+
+		_, _, err = loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+		assert.ErrorContains(t, err, "invalid timeuot header: unknown key")
+
+		fsys = fstest.MapFS{
+			"user/GetUser.sql": {Data: []byte("-- +meta\n-- timeout: 5s\nSELECT * FROM users WHERE id = {{ .id }}")},
+		}
+
+		_, _, err = loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+		assert.ErrorContains(t, err, "metadata header is not closed with -- +end")
+	})
+
+	t.Run("Failed loading an FS root reports the path inside the FS", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"user/GetUser.sql": {Data: []byte("-- +meta\n-- timeout: soon\n-- +end\nSELECT * FROM users WHERE id = {{ .id }}")},
+		}
+
+		_, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys, Location: "/srv/queries"})}, "")
+		assert.ErrorContains(t, err, "error parsing runner user.GetUser in user/GetUser.sql")
+		assert.NotContains(t, err.Error(), "/srv/queries")
+	})
+
+	t.Run("Failed loading a query before the first name marker", func(t *testing.T) {
+		t.Parallel()
+
+		fsys := fstest.MapFS{
+			"user.sql": {Data: []byte("SELECT 1;\n-- name: GetUser\nSELECT * FROM users WHERE id = {{ .id }};\n")},
+		}
+
+		_, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+		assert.ErrorContains(t, err, "query found before the first name marker")
+	})
+
+	t.Run("Failed loading from a missing directory", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys, FSRoot: "missing"})}, "")
+		assert.Error(t, err)
+	})
+}
+
+func TestCompileQueries(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success compiling every query", func(t *testing.T) {
+		t.Parallel()
+
+		runners := map[string]query{
+			"user.GetUser":   {sql: "SELECT * FROM users WHERE id = {{ .id }}"},
+			"user.ListUsers": {sql: "SELECT * FROM users"},
+		}
+
+		err := compileQueries(parser.New(), runners)
+		assert.NoError(t, err)
+		assert.NotNil(t, runners["user.GetUser"].tmpl)
+		assert.NotNil(t, runners["user.ListUsers"].tmpl)
+	})
+
+	t.Run("Failed compiling reports every broken query", func(t *testing.T) {
+		t.Parallel()
+
+		runners := map[string]query{
+			"user.GetUser":   {sql: "SELECT * FROM users WHERE id = {{ .id "},
+			"user.ListUsers": {sql: "SELECT * FROM users"},
+			"user.CountUser": {sql: "SELECT COUNT(*) FROM users {{ end }}"},
+		}
+
+		err := compileQueries(parser.New(), runners)
+
+		var errs TemplateErrors
+		assert.ErrorAs(t, err, &errs)
+		assert.Len(t, errs, 2)
+		assert.Equal(t, "user.CountUser", errs[0].RunnerCode)
+		assert.Equal(t, "user.GetUser", errs[1].RunnerCode)
+	})
+}
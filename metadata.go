@@ -0,0 +1,178 @@
+package fayl
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Cardinality is the expected number of rows returned by a query.
+type Cardinality string
+
+const (
+	// CardinalityNone is for statements that return no rows, they can only be run with Exec.
+	CardinalityNone Cardinality = "none"
+	// CardinalityOne is for queries that return a single row, they can only be scanned with ScanMap, ScanStruct, ScanValue or ScanFunc.
+	CardinalityOne Cardinality = "one"
+	// CardinalityMany is for queries that return any number of rows.
+	CardinalityMany Cardinality = "many"
+)
+
+// Metadata is the per-runner configuration declared in the header of a query.
+// The header is a block of "-- key: value" comment lines between a "-- +meta" and a "-- +end" line,
+// placed before the SQL of the query, e.g.
+//
+//	-- +meta
+//	-- description: Get a user by its id
+//	-- timeout: 5s
+//	-- read-only: true
+//	-- cardinality: one
+//	-- tags: user, profile
+//	-- cache-ttl: 1m
+//	-- deprecated: use user.GetUserV2 instead
+//	-- param: id int required
+//	-- +end
+//	SELECT * FROM users WHERE id = {{ .id }}
+//
+// Comments outside of the block are plain SQL comments, they are never parsed as metadata.
+type Metadata struct {
+	// Description describes what the query does.
+	Description string
+	// Timeout is applied to the context of Exec and Query, zero means no timeout.
+	Timeout time.Duration
+	// ReadOnly refuses Exec on the runner.
+	ReadOnly bool
+	// Cardinality is the expected number of rows returned by the query, empty means any.
+	Cardinality Cardinality
+	// Tags are free-form labels of the query.
+	Tags []string
+	// CacheTTL is how long the result of the query may be cached, zero means no caching.
+	CacheTTL time.Duration
+	// Deprecated reports that the runner should not be used anymore, running it logs a warning.
+	Deprecated bool
+	// DeprecationNote explains what to use instead of a deprecated runner.
+	DeprecationNote string
+	// Params are the param declarations of the query, see Param.
+	Params []Param
+}
+
+// headerLine matches a "-- key: value" line of a query header.
+var headerLine = regexp.MustCompile(`^--[ \t]*([A-Za-z_-]+)[ \t]*:[ \t]*(.*?)[ \t]*$`)
+
+// headerStart and headerEnd delimit the header of a query.
+var (
+	headerStart = regexp.MustCompile(`^--[ \t]*\+meta$`)
+	headerEnd   = regexp.MustCompile(`^--[ \t]*\+end$`)
+)
+
+// parseMetadata parses the header of the given query.
+// The header is only looked for in the comments and blank lines at the top of the query.
+// It returns the metadata and the query with the lines of its header blanked,
+// so the line numbers in the template errors still match the query file.
+// A comment line of the header that is not a "-- key: value" line is ignored, an unknown key is an error.
+func parseMetadata(query string) (Metadata, string, error) {
+
+	var (
+		metadata Metadata
+		lines    = strings.Split(query, "\n")
+		start    = -1
+	)
+
+	for i := range lines {
+
+		line := strings.TrimSpace(lines[i])
+
+		if start < 0 {
+
+			// The header must start before the first line that is neither blank nor a comment
+			if line != "" && !strings.HasPrefix(line, "--") {
+				break
+			}
+			if headerStart.MatchString(line) {
+				start = i
+			}
+			continue
+
+		}
+
+		if headerEnd.MatchString(line) {
+			for j := start; j <= i; j++ {
+				lines[j] = ""
+			}
+			return metadata, strings.Join(lines, "\n"), nil
+		}
+
+		if !strings.HasPrefix(line, "--") {
+			return Metadata{}, "", errors.New("metadata header is not closed with -- +end")
+		}
+
+		m := headerLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		if err := metadata.set(m[1], m[2]); err != nil {
+			return Metadata{}, "", err
+		}
+
+	}
+
+	if start >= 0 {
+		return Metadata{}, "", errors.New("metadata header is not closed with -- +end")
+	}
+
+	return metadata, query, nil
+
+}
+
+// set sets the field of the metadata identified by the given header key.
+func (m *Metadata) set(key, value string) error {
+
+	var err error
+
+	switch strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key)) {
+	case "description":
+		m.Description = value
+	case "timeout":
+		m.Timeout, err = time.ParseDuration(value)
+	case "readonly":
+		m.ReadOnly, err = strconv.ParseBool(value)
+	case "cardinality":
+		m.Cardinality = Cardinality(strings.ToLower(value))
+		switch m.Cardinality {
+		case CardinalityNone, CardinalityOne, CardinalityMany:
+		default:
+			err = errors.New("must be one of none, one or many")
+		}
+	case "tags":
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				m.Tags = append(m.Tags, tag)
+			}
+		}
+	case "cachettl":
+		m.CacheTTL, err = time.ParseDuration(value)
+	case "deprecated":
+		// the value is either a boolean or a note explaining what to use instead
+		if m.Deprecated, err = strconv.ParseBool(value); err != nil {
+			m.Deprecated, m.DeprecationNote, err = true, value, nil
+		}
+	case "param":
+		var p Param
+		if p, err = parseParam(value); err == nil {
+			m.Params = append(m.Params, p)
+		}
+	default:
+		err = errors.New("unknown key")
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "invalid %s header", key)
+	}
+
+	return nil
+
+}
@@ -121,6 +121,6 @@ func processTabling(_ context.Context, client *Client, tabling *Tabling, query s
 		return res, nil
 	}
 
-	return nil, nil
+	return &kuysor.Result{Query: query, Args: parameters}, nil
 
 }
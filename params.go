@@ -0,0 +1,312 @@
+package fayl
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/redhajuanda/fayl/vars"
+
+	"github.com/pkg/errors"
+)
+
+// ParamType is the expected type of a param.
+type ParamType string
+
+const (
+	// ParamTypeAny accepts any value, it is the default.
+	ParamTypeAny ParamType = "any"
+	// ParamTypeString accepts strings.
+	ParamTypeString ParamType = "string"
+	// ParamTypeInt accepts signed and unsigned integers.
+	ParamTypeInt ParamType = "int"
+	// ParamTypeFloat accepts floats and integers.
+	ParamTypeFloat ParamType = "float"
+	// ParamTypeBool accepts booleans.
+	ParamTypeBool ParamType = "bool"
+	// ParamTypeTime accepts time.Time values.
+	ParamTypeTime ParamType = "time"
+)
+
+// Param is the declaration of a runner param.
+// The params of a runner are validated against its declarations before its query is parsed.
+//
+// Params are declared in the header of the query, one per line:
+//
+//	-- param: email string required max=255
+//	-- param: status string oneof=active|inactive
+//	-- param: limit int
+//
+// or with a struct registered with Client.RegisterParams.
+type Param struct {
+	// Name is the name of the param.
+	Name string
+	// Type is the expected type of the value, it defaults to ParamTypeAny.
+	Type ParamType
+	// Required fails the validation if the param is not set or is nil.
+	Required bool
+	// OneOf lists the allowed values, compared with the string representation of the value.
+	OneOf []string
+	// MaxLength is the maximum length of a string or a slice, zero means no limit.
+	MaxLength int
+}
+
+// ValidationError is returned when the params of a runner do not satisfy its param declarations.
+// It lists every failing field, so it can be mapped to a 400 response.
+type ValidationError struct {
+	RunnerCode string
+	Fields     []FieldError
+}
+
+// FieldError is a param that failed the validation.
+type FieldError struct {
+	// Field is the name of the param.
+	Field string
+	// Rule is the rule that failed: required, type, oneof or max.
+	Rule string
+	// Message describes the failure.
+	Message string
+}
+
+// Error returns the error message listing every failing field.
+func (e *ValidationError) Error() string {
+
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+
+	return fmt.Sprintf("runner %s: invalid params: %s", e.RunnerCode, strings.Join(msgs, "; "))
+
+}
+
+// parseParam parses a param declared in the header of a query, e.g. "email string required max=255".
+func parseParam(value string) (Param, error) {
+
+	tokens := strings.Fields(value)
+	if len(tokens) == 0 {
+		return Param{}, errors.New("param name is required")
+	}
+
+	p := Param{Name: tokens[0], Type: ParamTypeAny}
+	tokens = tokens[1:]
+
+	// the type is optional and comes right after the name
+	if len(tokens) > 0 && !strings.Contains(tokens[0], "=") && tokens[0] != "required" {
+		p.Type = ParamType(tokens[0])
+		tokens = tokens[1:]
+	}
+
+	if err := p.setRules(tokens); err != nil {
+		return Param{}, err
+	}
+
+	return p, nil
+
+}
+
+// setRules sets the rules of the param from tokens such as required, max=255 or oneof=a|b.
+func (p *Param) setRules(tokens []string) error {
+
+	switch p.Type {
+	case ParamTypeAny, ParamTypeString, ParamTypeInt, ParamTypeFloat, ParamTypeBool, ParamTypeTime:
+	default:
+		return errors.Errorf("param %s has an unknown type %s", p.Name, p.Type)
+	}
+
+	for _, token := range tokens {
+
+		rule, value, _ := strings.Cut(strings.TrimSpace(token), "=")
+
+		switch rule {
+		case "":
+		case "required":
+			p.Required = true
+		case "max":
+			max, err := strconv.Atoi(value)
+			if err != nil {
+				return errors.Errorf("param %s has an invalid max %s", p.Name, value)
+			}
+			p.MaxLength = max
+		case "oneof":
+			p.OneOf = strings.Split(value, "|")
+		default:
+			return errors.Errorf("param %s has an unknown rule %s", p.Name, rule)
+		}
+
+	}
+
+	return nil
+
+}
+
+// paramsFromStruct returns the param declarations of the given struct.
+// The name of a param is the fayl tag of its field, or the field name if it has no tag,
+// its type is derived from the field type and its rules are read from the param tag, e.g. `param:"required,max=255,oneof=a|b"`.
+func paramsFromStruct(schema any) ([]Param, error) {
+
+	t := reflect.TypeOf(schema)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("params schema must be a struct")
+	}
+
+	params := make([]Param, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get(vars.TagKey), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		p := Param{Name: name, Type: paramType(field.Type)}
+		if err := p.setRules(strings.Split(field.Tag.Get("param"), ",")); err != nil {
+			return nil, err
+		}
+
+		params = append(params, p)
+
+	}
+
+	return params, nil
+
+}
+
+// paramType returns the param type matching the given Go type.
+func paramType(t reflect.Type) ParamType {
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return ParamTypeTime
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return ParamTypeString
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return ParamTypeInt
+	case reflect.Float32, reflect.Float64:
+		return ParamTypeFloat
+	case reflect.Bool:
+		return ParamTypeBool
+	}
+
+	return ParamTypeAny
+
+}
+
+// validateParams validates the given params against their declarations.
+// It returns a *ValidationError listing every failing field, if any.
+func validateParams(runnerCode string, params map[string]any, declarations []Param) error {
+
+	var fields []FieldError
+
+	for _, p := range declarations {
+		if f, ok := p.validate(params[p.Name]); !ok {
+			fields = append(fields, f)
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{
+			RunnerCode: runnerCode,
+			Fields:     fields,
+		}
+	}
+
+	return nil
+
+}
+
+// validate validates the given value against the param declaration.
+// It returns the failure and false if the value is invalid.
+func (p Param) validate(value any) (FieldError, bool) {
+
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+
+	if !v.IsValid() {
+		if p.Required {
+			return FieldError{Field: p.Name, Rule: "required", Message: "is required"}, false
+		}
+		return FieldError{}, true
+	}
+
+	if !p.Type.accepts(v) {
+		return FieldError{Field: p.Name, Rule: "type", Message: fmt.Sprintf("must be of type %s", p.Type)}, false
+	}
+
+	if p.MaxLength > 0 {
+		length := -1
+		switch v.Kind() {
+		case reflect.String:
+			length = utf8.RuneCountInString(v.String())
+		case reflect.Slice, reflect.Array, reflect.Map:
+			length = v.Len()
+		}
+		if length > p.MaxLength {
+			return FieldError{Field: p.Name, Rule: "max", Message: fmt.Sprintf("must not be longer than %d", p.MaxLength)}, false
+		}
+	}
+
+	if len(p.OneOf) > 0 {
+		s := StringValue(v.Interface())
+		allowed := false
+		for _, o := range p.OneOf {
+			if s == o {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return FieldError{Field: p.Name, Rule: "oneof", Message: "must be one of " + strings.Join(p.OneOf, ", ")}, false
+		}
+	}
+
+	return FieldError{}, true
+
+}
+
+// accepts reports whether the given value is of the param type.
+func (t ParamType) accepts(v reflect.Value) bool {
+
+	switch t {
+	case ParamTypeString:
+		return v.Kind() == reflect.String
+	case ParamTypeInt:
+		return v.CanInt() || v.CanUint()
+	case ParamTypeFloat:
+		return v.CanFloat() || v.CanInt() || v.CanUint()
+	case ParamTypeBool:
+		return v.Kind() == reflect.Bool
+	case ParamTypeTime:
+		_, ok := v.Interface().(time.Time)
+		return ok
+	}
+
+	return true
+
+}
@@ -0,0 +1,105 @@
+package fayl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParams(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success parsing params declared in the header", func(t *testing.T) {
+		t.Parallel()
+
+		metadata, _, err := parseMetadata("-- +meta\n-- param: email string required max=255\n-- param: status oneof=active|inactive\n-- +end\nSELECT 1")
+		require.NoError(t, err)
+
+		assert.Equal(t, []Param{
+			{Name: "email", Type: ParamTypeString, Required: true, MaxLength: 255},
+			{Name: "status", Type: ParamTypeAny, OneOf: []string{"active", "inactive"}},
+		}, metadata.Params)
+	})
+
+	t.Run("Failed parsing a param with an unknown rule", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := parseMetadata("-- +meta\n-- param: email string min=3\n-- +end\nSELECT 1")
+		assert.EqualError(t, err, "invalid param header: param email has an unknown rule min")
+	})
+
+	t.Run("Success deriving params from a struct", func(t *testing.T) {
+		t.Parallel()
+
+		params, err := paramsFromStruct(&struct {
+			Email     string    `fayl:"email" param:"required,max=255"`
+			Age       *int      `fayl:"age"`
+			CreatedAt time.Time `fayl:"created_at"`
+			Ignored   string    `fayl:"-"`
+		}{})
+		require.NoError(t, err)
+
+		assert.Equal(t, []Param{
+			{Name: "email", Type: ParamTypeString, Required: true, MaxLength: 255},
+			{Name: "age", Type: ParamTypeInt},
+			{Name: "created_at", Type: ParamTypeTime},
+		}, params)
+	})
+
+	t.Run("Success validating valid params", func(t *testing.T) {
+		t.Parallel()
+
+		params := []Param{
+			{Name: "email", Type: ParamTypeString, Required: true, MaxLength: 10},
+			{Name: "limit", Type: ParamTypeFloat},
+			{Name: "status", OneOf: []string{"1", "2"}},
+		}
+
+		assert.NoError(t, validateParams("user.ListUsers", map[string]any{"email": "a@b.c", "limit": 10, "status": 2}, params))
+	})
+
+	t.Run("Failed validating invalid params", func(t *testing.T) {
+		t.Parallel()
+
+		var email *string
+		params := []Param{
+			{Name: "email", Type: ParamTypeString, Required: true},
+			{Name: "name", Type: ParamTypeString, MaxLength: 3},
+			{Name: "age", Type: ParamTypeInt},
+			{Name: "status", OneOf: []string{"active", "inactive"}},
+		}
+
+		err := validateParams("user.CreateUser", map[string]any{"email": email, "name": "john", "age": "ten", "status": "deleted"}, params)
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []FieldError{
+			{Field: "email", Rule: "required", Message: "is required"},
+			{Field: "name", Rule: "max", Message: "must not be longer than 3"},
+			{Field: "age", Rule: "type", Message: "must be of type int"},
+			{Field: "status", Rule: "oneof", Message: "must be one of active, inactive"},
+		}, validationErr.Fields)
+		assert.EqualError(t, err, "runner user.CreateUser: invalid params: email is required; name must not be longer than 3; age must be of type int; status must be one of active, inactive")
+	})
+
+	t.Run("Failed validating params that failed to decode", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id"})
+		require.NoError(t, client.Register("user.CreateUser", "-- +meta\n-- param: email string required\n-- +end\nINSERT INTO users (email) VALUES ({{ .email }})"))
+
+		type createUserParams struct {
+			Email string `fayl:"email"`
+			Extra int    `fayl:",squash"`
+		}
+
+		_, _, err := client.Run("user.CreateUser").WithParams(&createUserParams{Email: "john@example.com"}).Build(context.Background())
+		assert.ErrorContains(t, err, "failed to decode params")
+
+		var validationErr *ValidationError
+		assert.NotErrorAs(t, err, &validationErr)
+	})
+}
@@ -1,10 +1,56 @@
 package parser
 
 import (
+	"encoding/json"
+	"fmt"
 	reflect "reflect"
+	"strings"
+	"text/template"
 	"time"
 )
 
+// builtins returns the functions available in every query template.
+//
+// The value returned by a function is bound as a parameter like any other value, so it is safe to use with user input:
+//
+//	IsTimeZero t            reports whether the time.Time t is zero
+//	IsTimeNotZero t         reports whether the time.Time t is not zero
+//	JSONOmitEmpty v         returns "__null__" if v is nil or zero, v otherwise
+//	like s                  returns the LIKE pattern matching values that contain s, e.g. %50\% off%
+//	ilike s                 same as like, to be used with ILIKE
+//	likePrefix s            returns the LIKE pattern matching values that start with s
+//	likeSuffix s            returns the LIKE pattern matching values that end with s
+//	escapeLike s            escapes the LIKE wildcards of s, to build a custom pattern
+//	json v                  returns the JSON encoding of v as a string
+//	coalesce v...           returns the first of its arguments that is neither nil nor zero
+//	join list sep           joins the elements of list with sep into a string
+//	add x y                 returns x + y
+//	sub x                   returns x - 1, e.g. to detect the last element of a range
+//	mul x y                 returns x * y
+//
+// The LIKE patterns escape %, _ and \ with a backslash, which is the default escape character of PostgreSQL and MySQL.
+// SQLite, SQL Server and Oracle require an explicit ESCAPE '\' clause.
+func builtins() template.FuncMap {
+
+	return template.FuncMap{
+		"IsTimeZero":    IsTimeZero,
+		"IsTimeNotZero": IsTimeNotZero,
+		"JSONOmitEmpty": JSONOmitEmpty,
+		"like":          Like,
+		"ilike":         Like,
+		"likePrefix":    LikePrefix,
+		"likeSuffix":    LikeSuffix,
+		"escapeLike":    EscapeLike,
+		"json":          JSON,
+		"coalesce":      Coalesce,
+		"join":          Join,
+		"add":           func(x, y int) int { return x + y },
+		"sub":           func(x int) int { return x - 1 },
+		"mul":           func(x, y int) int { return x * y },
+	}
+
+}
+
 // IsTimeZero checks if a time.Time value is zero (i.e., equal to the zero time).
 func IsTimeZero(t time.Time) bool {
 	return t.IsZero()
@@ -33,3 +79,104 @@ func JSONOmitEmpty(input interface{}) interface{} {
 	// Return the input as is
 	return input
 }
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes the LIKE wildcards of the given value, so it only matches itself.
+func EscapeLike(v any) string {
+	return likeEscaper.Replace(stringify(v))
+}
+
+// Like returns a LIKE pattern that matches the values containing the given value.
+func Like(v any) string {
+	return "%" + EscapeLike(v) + "%"
+}
+
+// LikePrefix returns a LIKE pattern that matches the values starting with the given value.
+func LikePrefix(v any) string {
+	return EscapeLike(v) + "%"
+}
+
+// LikeSuffix returns a LIKE pattern that matches the values ending with the given value.
+func LikeSuffix(v any) string {
+	return "%" + EscapeLike(v)
+}
+
+// JSON returns the JSON encoding of the given value.
+func JSON(v any) (string, error) {
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+
+}
+
+// Coalesce returns the first value that is neither nil nor the zero value of its type.
+// It returns nil if all the values are empty.
+func Coalesce(values ...any) any {
+
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			continue
+		}
+		if rv.IsZero() {
+			continue
+		}
+		return v
+	}
+
+	return nil
+
+}
+
+// Join joins the elements of the given slice with sep.
+func Join(list any, sep string) (string, error) {
+
+	if list == nil {
+		return "", nil
+	}
+
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: expected a slice, got %T", list)
+	}
+
+	elems := make([]string, v.Len())
+	for i := range elems {
+		elems[i] = stringify(v.Index(i).Interface())
+	}
+
+	return strings.Join(elems, sep), nil
+
+}
+
+// stringify returns the string representation of the given value, dereferencing pointers.
+func stringify(v any) string {
+
+	if v == nil {
+		return ""
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		return stringify(rv.Elem().Interface())
+	}
+
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	return fmt.Sprint(v)
+
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrIdentifierNotAllowed is returned when a template quotes an identifier that is not in the allowlist of the execution.
+var ErrIdentifierNotAllowed = errors.New("identifier not allowed")
+
+// identFunc is the name of the template function that quotes an identifier.
+const identFunc = "ident"
+
+type contextKey string
+
+// contextKeyIdentifiers is the context key of the identifiers allowed in an execution.
+const contextKeyIdentifiers = contextKey("identifiers")
+
+// ContextWithIdentifiers returns a copy of ctx that allows the given identifiers to be quoted by the ident function
+// when a template is executed with it.
+func ContextWithIdentifiers(ctx context.Context, identifiers ...string) context.Context {
+	return context.WithValue(ctx, contextKeyIdentifiers, identifiers)
+}
+
+// identifiersFromContext returns the identifiers allowed in ctx.
+func identifiersFromContext(ctx context.Context) []string {
+
+	identifiers, _ := ctx.Value(contextKeyIdentifiers).([]string)
+	return identifiers
+
+}
+
+// ident returns the ident template function of an execution.
+// The function quotes a table, schema or column name with the quoting of the dialect, and writes it into the query as is.
+// As it is not bound as a parameter, the name must be in the allowlist of the execution, anything else is rejected.
+func (t *Template) ident(allowed []string) func(name string) (string, error) {
+
+	return func(name string) (string, error) {
+
+		if err := allowIdentifier(allowed, name); err != nil {
+			return "", err
+		}
+
+		return t.dialect.QuoteIdentifier(name), nil
+
+	}
+
+}
+
+// allowIdentifier returns an error if the given identifier is not in the allowlist of the execution.
+func allowIdentifier(allowed []string, name string) error {
+
+	if name == "" || !slices.Contains(allowed, name) {
+		return errors.Wrapf(ErrIdentifierNotAllowed, "identifier %q", name)
+	}
+
+	// a question mark would be mistaken for a placeholder
+	if strings.Contains(name, "?") {
+		return errors.Errorf("identifier %q must not contain a question mark", name)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// named is a Parser that binds :name params instead of executing a template.
+type named struct {
+	emptySlice EmptySlicePolicy
+}
+
+// NewNamed returns a Parser for plain SQL queries with :name params, an alternative to the template engine of New.
+// Every :name is replaced with a placeholder bound to the param of the same name, and a missing param is an error.
+// Slice params are expanded like with the template engine, which is the only option that applies, see WithEmptySlice.
+// PostgreSQL casts such as ::int, string literals, quoted identifiers and comments are left untouched.
+// It does not implement Compiler: the queries are parsed on every run and the strict mode is not available.
+func NewNamed(opts ...Option) Parser {
+
+	p := New(opts...)
+
+	return &named{
+		emptySlice: p.emptySlice,
+	}
+
+}
+
+// Parse replaces the :name params of the given query with placeholders, and returns the query and its args.
+func (n *named) Parse(_ context.Context, query string, data map[string]any, placeholder Placeholder) (string, []any, error) {
+
+	var (
+		sb   strings.Builder
+		args []any
+	)
+
+	for i := 0; i < len(query); i++ {
+
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			// copy up to the closing quote, a doubled quote is an escaped quote
+			j := i + 1
+			for ; j < len(query); j++ {
+				if query[j] == c {
+					if j+1 < len(query) && query[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			writeEscaped(&sb, query[i:min(j+1, len(query))])
+			i = j
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			j := strings.IndexByte(query[i:], '\n')
+			if j < 0 {
+				j = len(query) - i
+			}
+			writeEscaped(&sb, query[i:i+j])
+			i += j - 1
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			j := strings.Index(query[i+2:], "*/")
+			end := len(query)
+			if j >= 0 {
+				end = i + 2 + j + 2
+			}
+			writeEscaped(&sb, query[i:end])
+			i = end - 1
+		case c == ':' && strings.HasPrefix(query[i:], "::"):
+			sb.WriteString("::")
+			i++
+		case c == ':' && i+1 < len(query) && isNameStart(query[i+1]):
+			j := i + 1
+			for j < len(query) && isNamePart(query[j]) {
+				j++
+			}
+			name := query[i+1 : j]
+			value, ok := data[name]
+			if !ok {
+				return "", nil, errors.Errorf("failed to parse query: missing param %s", name)
+			}
+			sb.WriteByte('?')
+			args = append(args, value)
+			i = j - 1
+		case c == '?':
+			// a literal question mark would be mistaken for a placeholder
+			sb.WriteString("??")
+		default:
+			sb.WriteByte(c)
+		}
+
+	}
+
+	query, args, err := interpolateQuery(sb.String(), args, n.emptySlice)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, err = placeholder.Format(query)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to compile query")
+	}
+
+	// the question mark format keeps ?? as is, unescape the literal question marks
+	if escaped, _ := placeholder.Format("??"); escaped == "??" {
+		query = strings.ReplaceAll(query, "??", "?")
+	}
+
+	return strings.TrimSpace(query), args, nil
+
+}
+
+// writeEscaped writes s to sb with its question marks escaped, so they are not mistaken for placeholders.
+func writeEscaped(sb *strings.Builder, s string) {
+	sb.WriteString(strings.ReplaceAll(s, "?", "??"))
+}
+
+// isNameStart reports whether c can start a param name.
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isNamePart reports whether c can be part of a param name.
+func isNamePart(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"text/template"
+
+	"github.com/redhajuanda/fayl/dialect"
+)
+
+// Option configures a parser.
+type Option func(*parser)
+
+// EmptySlicePolicy defines how a slice arg without elements is expanded.
+type EmptySlicePolicy int
+
+const (
+	// EmptySliceError fails the query with ErrEmptySlice, it is the default.
+	EmptySliceError EmptySlicePolicy = iota
+	// EmptySliceNull expands an empty slice to NULL, so IN ({{ .ids }}) becomes IN (NULL) and matches no row.
+	EmptySliceNull
+)
+
+// WithEmptySlice sets how a slice arg without elements is expanded.
+func WithEmptySlice(policy EmptySlicePolicy) Option {
+	return func(p *parser) {
+		p.emptySlice = policy
+	}
+}
+
+// WithFuncs adds the given functions to the functions available in every template.
+// A function with the same name as a builtin replaces it.
+func WithFuncs(funcs template.FuncMap) Option {
+	return func(p *parser) {
+		for name, fn := range funcs {
+			p.funcs[name] = fn
+		}
+	}
+}
+
+// WithDialect sets the dialect of the SQL generated by the template functions, e.g. the quoting of identifiers.
+func WithDialect(d dialect.Dialect) Option {
+	return func(p *parser) {
+		p.dialect = d
+	}
+}
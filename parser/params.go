@@ -0,0 +1,125 @@
+package parser
+
+import (
+	"sort"
+	"text/template"
+	"text/template/parse"
+)
+
+// Params returns the names of the params referenced by the template, sorted alphabetically.
+// A param is referenced as {{ .name }} or {{ $.name }}, including inside the partials the template includes with dot.
+// Fields referenced inside range and with blocks belong to the element being iterated, not to the params.
+func (t *Template) Params() []string {
+	return t.params
+}
+
+// templateParams walks the parse tree of tmpl and returns the names of the params it references.
+func templateParams(tmpl *template.Template) []string {
+
+	w := &paramsWalker{
+		tmpl:    tmpl,
+		params:  make(map[string]bool),
+		visited: make(map[string]bool),
+	}
+	w.walkTemplate(tmpl.Name())
+
+	params := make([]string, 0, len(w.params))
+	for name := range w.params {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+
+	return params
+
+}
+
+// paramsWalker collects the params referenced by a template and the templates it includes.
+type paramsWalker struct {
+	tmpl    *template.Template
+	params  map[string]bool
+	visited map[string]bool
+}
+
+// walkTemplate walks the named template once, with the params as dot.
+func (w *paramsWalker) walkTemplate(name string) {
+
+	if w.visited[name] {
+		return
+	}
+	w.visited[name] = true
+
+	t := w.tmpl.Lookup(name)
+	if t == nil || t.Tree == nil || t.Tree.Root == nil {
+		return
+	}
+
+	w.walk(t.Tree.Root, true)
+
+}
+
+// walk collects the params referenced by the given node.
+// root reports whether dot is the params at this point of the template.
+func (w *paramsWalker) walk(n parse.Node, root bool) {
+
+	switch v := n.(type) {
+	case *parse.ListNode:
+		if v == nil {
+			return
+		}
+		for _, n := range v.Nodes {
+			w.walk(n, root)
+		}
+	case *parse.ActionNode:
+		w.walk(v.Pipe, root)
+	case *parse.IfNode:
+		w.walk(v.Pipe, root)
+		w.walk(v.List, root)
+		w.walk(v.ElseList, root)
+	case *parse.RangeNode:
+		// dot is the element being iterated inside the range
+		w.walk(v.Pipe, root)
+		w.walk(v.List, false)
+		w.walk(v.ElseList, root)
+	case *parse.WithNode:
+		// dot is the value of the pipeline inside the with
+		w.walk(v.Pipe, root)
+		w.walk(v.List, false)
+		w.walk(v.ElseList, root)
+	case *parse.TemplateNode:
+		w.walk(v.Pipe, root)
+		if v.Pipe != nil && len(v.Pipe.Cmds) == 1 && len(v.Pipe.Cmds[0].Args) == 1 {
+			switch arg := v.Pipe.Cmds[0].Args[0].(type) {
+			case *parse.DotNode:
+				if root {
+					w.walkTemplate(v.Name)
+				}
+			case *parse.VariableNode:
+				if len(arg.Ident) == 1 && arg.Ident[0] == "$" {
+					w.walkTemplate(v.Name)
+				}
+			}
+		}
+	case *parse.PipeNode:
+		if v == nil {
+			return
+		}
+		for _, cmd := range v.Cmds {
+			w.walk(cmd, root)
+		}
+	case *parse.CommandNode:
+		for _, arg := range v.Args {
+			w.walk(arg, root)
+		}
+	case *parse.ChainNode:
+		w.walk(v.Node, root)
+	case *parse.FieldNode:
+		if root {
+			w.params[v.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if len(v.Ident) > 1 && v.Ident[0] == "$" {
+			w.params[v.Ident[1]] = true
+		}
+	}
+
+}
@@ -2,23 +2,119 @@ package parser
 
 import (
 	"context"
-	"html/template"
+	"database/sql/driver"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/redhajuanda/fayl/dialect"
 
-	"github.com/VauntDev/tqla"
-	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
+// ErrEmptySlice is returned when a slice arg without elements is expanded, see WithEmptySlice.
+var ErrEmptySlice = errors.New("cannot expand an empty slice")
+
+// Parser renders a query template with the given data into a query and its args,
+// formatting the placeholders of the query with the given placeholder format.
+// It is the extension point to plug an alternative template engine into the client, see NewNamed.
+//
 //go:generate mockgen --source=parser.go --destination=parser_mock.go --package=parser
 type Parser interface {
-	Parse(ctx context.Context, queryTemplate string, data map[string]any) (string, []interface{}, error)
+	Parse(ctx context.Context, queryTemplate string, data map[string]any, placeholder Placeholder) (string, []any, error)
+}
+
+// Compiler is implemented by the parsers that compile a query template once to execute it many times.
+// The queries of such a parser are compiled at Init, so a broken template fails Init instead of the query that runs it,
+// and their params are known, which the strict mode requires.
+type Compiler interface {
+	Compile(name, queryTemplate string) (*Template, error)
+}
+
+// PartialSetter is implemented by the parsers that support partials.
+// SetPartials replaces all the partials of the parser with the given ones, keyed by their name.
+// It is called again with the new partials whenever the query files are reloaded.
+type PartialSetter interface {
+	SetPartials(partials map[string]string) error
+}
+
+type parser struct {
+	funcs      template.FuncMap
+	mu         sync.RWMutex
+	partials   []*template.Template
+	emptySlice EmptySlicePolicy
+	dialect    dialect.Dialect
+}
+
+func New(opts ...Option) *parser {
+
+	p := &parser{
+		funcs: builtins(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+
+}
+
+// AddPartial registers a partial template.
+// Every template compiled afterwards can include it with {{ template "name" . }}.
+// The partial may itself define more named templates with {{ define "name" }}.
+func (p *parser) AddPartial(name, partialTemplate string) error {
+
+	tmpl, err := template.New(name).Funcs(p.parseFuncs()).Parse(partialTemplate)
+	if err != nil {
+		return errors.Wrapf(err, "failed to parse partial %s", name)
+	}
+
+	p.mu.Lock()
+	p.partials = append(p.partials, tmpl)
+	p.mu.Unlock()
+
+	return nil
+
+}
+
+// SetPartials replaces all the partials of the parser with the given ones, keyed by their name.
+// The partials are parsed in the order of their names, and none is replaced if one of them fails to parse.
+// The templates compiled before keep the partials they were compiled with.
+func (p *parser) SetPartials(partials map[string]string) error {
+
+	names := make([]string, 0, len(partials))
+	for name := range partials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tmpls := make([]*template.Template, 0, len(names))
+	for _, name := range names {
+		tmpl, err := template.New(name).Funcs(p.parseFuncs()).Parse(partials[name])
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse partial %s", name)
+		}
+		tmpls = append(tmpls, tmpl)
+	}
+
+	p.mu.Lock()
+	p.partials = tmpls
+	p.mu.Unlock()
+
+	return nil
+
 }
 
-type parser struct{}
+// loadPartials returns the partials of the parser.
+func (p *parser) loadPartials() []*template.Template {
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.partials
 
-func New() *parser {
-	return &parser{}
 }
 
 type Placeholder interface {
@@ -26,49 +122,142 @@ type Placeholder interface {
 }
 
 // Parse parses the given query by replacing the parameters with placeholders.
-// the sanitization is performed using the tqla package.
+// the sanitization follows the approach of the tqla package.
 // tqla is a small light weight text parser that wraps the golang text/template standard library.
 // the primary purpose of tqla is to parse a text template and replace any variable with a placeholder.
 // variables that are replaced with placeholders are added to an args slice that can be passed to standard db driver.
 // tqla prevents sql injection by leveraging DB placeholders as described in the following article:
 // https://go.dev/doc/database/sql-injection
+// Parse compiles the template on every call, use Compile to compile a template once and execute it many times.
 func (p *parser) Parse(ctx context.Context, queryTemplate string, data map[string]any, placeholder Placeholder) (string, []interface{}, error) {
 
 	// ctx, span := otel.Start(ctx)
 	// defer span.End()
 
-	// create a new parser with the default placeholder set to ?
-	parser, err := tqla.New(tqla.WithPlaceHolder(placeholder), tqla.WithFuncMap(template.FuncMap{
-		"IsTimeZero":    IsTimeZero,
-		"IsTimeNotZero": IsTimeNotZero,
-		"sub":           func(x int) int { return x - 1 },
-	}))
+	tmpl, err := p.Compile("query", queryTemplate)
 	if err != nil {
-		return "", nil, errors.Wrap(err, "failed to parse query")
+		return "", nil, err
 	}
 
-	// compile query
-	query, parameters, err := parser.Compile(queryTemplate, data)
-	if err != nil {
-		return "", nil, errors.Wrap(err, "failed to compile query")
+	return tmpl.Execute(ctx, data, placeholder)
+
+}
+
+// interpolateQuery expands every slice arg of the given query into one placeholder per element,
+// so IN ({{ .ids }}) becomes IN (?, ?, ?).
+// The query must still use ? placeholders: it runs before the placeholders are formatted,
+// so the expanded placeholders are numbered correctly whatever the placeholder format is.
+// Like in tqla, ?? is an escaped question mark and not a placeholder.
+// The row cells bound by the values and upsert functions are never expanded.
+func interpolateQuery(query string, args []any, emptySlice EmptySlicePolicy) (string, []any, error) {
+
+	// most queries have no slice arg, return them untouched
+	expand := false
+	for _, arg := range args {
+		if _, ok := sliceValue(arg); ok {
+			expand = true
+			break
+		}
+	}
+	if !expand {
+		for i, arg := range args {
+			args[i] = cellValue(arg)
+		}
+		return query, args, nil
+	}
+
+	var (
+		sb         strings.Builder
+		parameters = make([]any, 0, len(args))
+		i          int
+	)
+
+	for {
+
+		p := strings.IndexByte(query, '?')
+		if p == -1 {
+			break
+		}
+
+		sb.WriteString(query[:p])
+
+		// keep the escaped question mark as is, the placeholder format unescapes it
+		if strings.HasPrefix(query[p:], "??") {
+			sb.WriteString("??")
+			query = query[p+2:]
+			continue
+		}
+		query = query[p+1:]
+
+		if i >= len(args) {
+			return "", nil, errors.New("failed to interpolate query: more placeholders than args")
+		}
+		arg := args[i]
+		i++
+
+		v, ok := sliceValue(arg)
+		if !ok {
+			sb.WriteByte('?')
+			parameters = append(parameters, cellValue(arg))
+			continue
+		}
+
+		if v.Len() == 0 {
+			if emptySlice == EmptySliceNull {
+				sb.WriteString("NULL")
+				continue
+			}
+			return "", nil, errors.Wrapf(ErrEmptySlice, "failed to interpolate arg %d", i)
+		}
+
+		for j := 0; j < v.Len(); j++ {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteByte('?')
+			parameters = append(parameters, v.Index(j).Interface())
+		}
+
+	}
+
+	if i != len(args) {
+		return "", nil, errors.New("failed to interpolate query: more args than placeholders")
 	}
 
-	query = strings.TrimSpace(query)
+	sb.WriteString(query)
 
-	// interpolate query
-	return p.interpolateQuery(ctx, query, parameters...)
+	return sb.String(), parameters, nil
 
 }
 
-// interpolateQuery interpolates the given query with the provided parameters.
-func (p *parser) interpolateQuery(_ context.Context, query string, args ...interface{}) (string, []interface{}, error) {
+// sliceValue returns the reflect value of arg if it is a slice or an array to expand.
+// Byte slices and driver.Valuer implementations are passed to the driver as a single value.
+func sliceValue(arg any) (reflect.Value, bool) {
 
-	// interpolate query
-	query, parameters, err := sqlx.In(query, args...)
-	if err != nil {
-		return "", nil, errors.Wrap(err, "failed to interpolate query")
+	if arg == nil {
+		return reflect.Value{}, false
+	}
+
+	if _, ok := arg.(driver.Valuer); ok {
+		return reflect.Value{}, false
+	}
+
+	v := reflect.ValueOf(arg)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return reflect.Value{}, false
+		}
+		return v, true
 	}
 
-	return query, parameters, nil
+	return reflect.Value{}, false
 
 }
@@ -41,9 +41,9 @@ func (m *MockParser) EXPECT() *MockParserMockRecorder {
 }
 
 // Parse mocks base method.
-func (m *MockParser) Parse(ctx context.Context, queryTemplate string, data map[string]any) (string, []any, error) {
+func (m *MockParser) Parse(ctx context.Context, queryTemplate string, data map[string]any, placeholder Placeholder) (string, []any, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Parse", ctx, queryTemplate, data)
+	ret := m.ctrl.Call(m, "Parse", ctx, queryTemplate, data, placeholder)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].([]any)
 	ret2, _ := ret[2].(error)
@@ -51,7 +51,123 @@ func (m *MockParser) Parse(ctx context.Context, queryTemplate string, data map[s
 }
 
 // Parse indicates an expected call of Parse.
-func (mr *MockParserMockRecorder) Parse(ctx, queryTemplate, data any) *gomock.Call {
+func (mr *MockParserMockRecorder) Parse(ctx, queryTemplate, data, placeholder any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Parse", reflect.TypeOf((*MockParser)(nil).Parse), ctx, queryTemplate, data)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Parse", reflect.TypeOf((*MockParser)(nil).Parse), ctx, queryTemplate, data, placeholder)
+}
+
+// MockCompiler is a mock of Compiler interface.
+type MockCompiler struct {
+	ctrl     *gomock.Controller
+	recorder *MockCompilerMockRecorder
+	isgomock struct{}
+}
+
+// MockCompilerMockRecorder is the mock recorder for MockCompiler.
+type MockCompilerMockRecorder struct {
+	mock *MockCompiler
+}
+
+// NewMockCompiler creates a new mock instance.
+func NewMockCompiler(ctrl *gomock.Controller) *MockCompiler {
+	mock := &MockCompiler{ctrl: ctrl}
+	mock.recorder = &MockCompilerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCompiler) EXPECT() *MockCompilerMockRecorder {
+	return m.recorder
+}
+
+// Compile mocks base method.
+func (m *MockCompiler) Compile(name, queryTemplate string) (*Template, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Compile", name, queryTemplate)
+	ret0, _ := ret[0].(*Template)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Compile indicates an expected call of Compile.
+func (mr *MockCompilerMockRecorder) Compile(name, queryTemplate any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Compile", reflect.TypeOf((*MockCompiler)(nil).Compile), name, queryTemplate)
+}
+
+// MockPartialSetter is a mock of PartialSetter interface.
+type MockPartialSetter struct {
+	ctrl     *gomock.Controller
+	recorder *MockPartialSetterMockRecorder
+	isgomock struct{}
+}
+
+// MockPartialSetterMockRecorder is the mock recorder for MockPartialSetter.
+type MockPartialSetterMockRecorder struct {
+	mock *MockPartialSetter
+}
+
+// NewMockPartialSetter creates a new mock instance.
+func NewMockPartialSetter(ctrl *gomock.Controller) *MockPartialSetter {
+	mock := &MockPartialSetter{ctrl: ctrl}
+	mock.recorder = &MockPartialSetterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPartialSetter) EXPECT() *MockPartialSetterMockRecorder {
+	return m.recorder
+}
+
+// SetPartials mocks base method.
+func (m *MockPartialSetter) SetPartials(partials map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPartials", partials)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPartials indicates an expected call of SetPartials.
+func (mr *MockPartialSetterMockRecorder) SetPartials(partials any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPartials", reflect.TypeOf((*MockPartialSetter)(nil).SetPartials), partials)
+}
+
+// MockPlaceholder is a mock of Placeholder interface.
+type MockPlaceholder struct {
+	ctrl     *gomock.Controller
+	recorder *MockPlaceholderMockRecorder
+	isgomock struct{}
+}
+
+// MockPlaceholderMockRecorder is the mock recorder for MockPlaceholder.
+type MockPlaceholderMockRecorder struct {
+	mock *MockPlaceholder
+}
+
+// NewMockPlaceholder creates a new mock instance.
+func NewMockPlaceholder(ctrl *gomock.Controller) *MockPlaceholder {
+	mock := &MockPlaceholder{ctrl: ctrl}
+	mock.recorder = &MockPlaceholderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPlaceholder) EXPECT() *MockPlaceholderMockRecorder {
+	return m.recorder
+}
+
+// Format mocks base method.
+func (m *MockPlaceholder) Format(sql string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Format", sql)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Format indicates an expected call of Format.
+func (mr *MockPlaceholderMockRecorder) Format(sql any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Format", reflect.TypeOf((*MockPlaceholder)(nil).Format), sql)
 }
@@ -0,0 +1,469 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/redhajuanda/fayl/dialect"
+
+	"github.com/VauntDev/tqla"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateExecute(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success executing a compiled template many times", func(t *testing.T) {
+		t.Parallel()
+
+		tmpl, err := New().Compile("user.GetUser", `
+			SELECT * FROM users
+			WHERE id = {{ .id }}
+			{{ if .name }}AND name = {{ .name }}{{ end }}`)
+		assert.NoError(t, err)
+
+		query, args, err := tmpl.Execute(context.Background(), map[string]any{"id": 1, "name": "john"}, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM users WHERE id = $1 AND name = $2", query)
+		assert.Equal(t, []any{1, "john"}, args)
+
+		query, args, err = tmpl.Execute(context.Background(), map[string]any{"id": 2}, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM users WHERE id = $1", query)
+		assert.Equal(t, []any{2}, args)
+	})
+
+	t.Run("Success executing a template that includes partials", func(t *testing.T) {
+		t.Parallel()
+
+		ps := New()
+		assert.NoError(t, ps.AddPartial("user.columns", "id, name, email"))
+		assert.NoError(t, ps.AddPartial("tenant", "tenant_id = {{ .tenant_id }}"))
+
+		tmpl, err := ps.Compile("user.GetUser", `SELECT {{ template "user.columns" . }} FROM users WHERE {{ template "tenant" . }} AND id = {{ .id }}`)
+		assert.NoError(t, err)
+
+		query, args, err := tmpl.Execute(context.Background(), map[string]any{"id": 1, "tenant_id": "acme"}, tqla.Question)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT id, name, email FROM users WHERE tenant_id = ? AND id = ?", query)
+		assert.Equal(t, []any{"acme", 1}, args)
+	})
+
+	t.Run("Failed replacing the partials with an invalid partial", func(t *testing.T) {
+		t.Parallel()
+
+		ps := New()
+		assert.NoError(t, ps.SetPartials(map[string]string{"user.columns": "id, name"}))
+
+		err := ps.SetPartials(map[string]string{"user.columns": "id", "tenant": "tenant_id = {{ .tenant_id "})
+		assert.ErrorContains(t, err, "failed to parse partial tenant")
+
+		// the previous partials are kept
+		query, _, err := ps.Parse(context.Background(), `SELECT {{ template "user.columns" . }} FROM users`, nil, tqla.Question)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT id, name FROM users", query)
+	})
+
+	t.Run("Failed compiling an invalid template", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := New().Compile("user.GetUser", "SELECT * FROM users WHERE id = {{ .id ")
+		assert.Error(t, err)
+	})
+}
+
+func TestSliceExpansion(t *testing.T) {
+	t.Parallel()
+
+	const queryTemplate = `SELECT * FROM users WHERE status = {{ .status }} AND id IN ({{ .ids }}) AND role IN ({{ .roles }}) AND tags ?? 'admin' AND avatar = {{ .avatar }}`
+
+	data := map[string]any{
+		"status": "active",
+		"ids":    []int64{1, 2, 3},
+		"roles":  [2]string{"admin", "owner"},
+		"avatar": []byte("png"),
+	}
+	expectedArgs := []any{"active", int64(1), int64(2), int64(3), "admin", "owner", []byte("png")}
+
+	testCases := []struct {
+		name          string
+		placeholder   Placeholder
+		expectedQuery string
+	}{
+		{
+			name:          "Question",
+			placeholder:   tqla.Question,
+			expectedQuery: "SELECT * FROM users WHERE status = ? AND id IN (?, ?, ?) AND role IN (?, ?) AND tags ?? 'admin' AND avatar = ?",
+		},
+		{
+			name:          "Dollar",
+			placeholder:   tqla.Dollar,
+			expectedQuery: "SELECT * FROM users WHERE status = $1 AND id IN ($2, $3, $4) AND role IN ($5, $6) AND tags ? 'admin' AND avatar = $7",
+		},
+		{
+			name:          "Colon",
+			placeholder:   tqla.Colon,
+			expectedQuery: "SELECT * FROM users WHERE status = :1 AND id IN (:2, :3, :4) AND role IN (:5, :6) AND tags ? 'admin' AND avatar = :7",
+		},
+		{
+			name:          "AtP",
+			placeholder:   tqla.AtP,
+			expectedQuery: "SELECT * FROM users WHERE status = @p1 AND id IN (@p2, @p3, @p4) AND role IN (@p5, @p6) AND tags ? 'admin' AND avatar = @p7",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run("Success expanding slices with "+tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, args, err := New().Parse(context.Background(), queryTemplate, data, tc.placeholder)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedQuery, query)
+			assert.Equal(t, expectedArgs, args)
+		})
+
+		t.Run("Failed expanding an empty slice with "+tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, err := New().Parse(context.Background(), "SELECT * FROM users WHERE id IN ({{ .ids }})", map[string]any{"ids": []int64{}}, tc.placeholder)
+			assert.ErrorIs(t, err, ErrEmptySlice)
+		})
+
+		t.Run("Success expanding an empty slice to NULL with "+tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, args, err := New(WithEmptySlice(EmptySliceNull)).Parse(context.Background(), "SELECT * FROM users WHERE id IN ({{ .ids }}) AND status = {{ .status }}", map[string]any{"ids": []int64{}, "status": "active"}, tc.placeholder)
+			assert.NoError(t, err)
+			assert.Contains(t, query, "id IN (NULL) AND status = ")
+			assert.Equal(t, []any{"active"}, args)
+		})
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success binding the result of builtin functions", func(t *testing.T) {
+		t.Parallel()
+
+		query, args, err := New().Parse(context.Background(), `
+			SELECT * FROM products
+			WHERE name ILIKE {{ ilike .name }}
+			AND code LIKE {{ likePrefix .code }}
+			AND attributes = {{ json .attributes }}
+			AND status = {{ coalesce .status "active" }}
+			AND tags = {{ join .tags "," }}
+			LIMIT {{ mul .per_page 2 }} OFFSET {{ add .offset 1 }}`,
+			map[string]any{
+				"name":       "50% off_",
+				"code":       `A\B`,
+				"attributes": map[string]any{"color": "red"},
+				"status":     "",
+				"tags":       []string{"new", "sale"},
+				"per_page":   10,
+				"offset":     20,
+			}, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM products WHERE name ILIKE $1 AND code LIKE $2 AND attributes = $3 AND status = $4 AND tags = $5 LIMIT $6 OFFSET $7", query)
+		assert.Equal(t, []any{`%50\% off\_%`, `A\\B%`, `{"color":"red"}`, "active", "new,sale", 20, 21}, args)
+	})
+
+	t.Run("Success using custom functions", func(t *testing.T) {
+		t.Parallel()
+
+		ps := New(WithFuncs(template.FuncMap{"upper": strings.ToUpper}))
+
+		query, args, err := ps.Parse(context.Background(), "SELECT * FROM users WHERE code = {{ upper .code }}", map[string]any{"code": "abc"}, tqla.Question)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM users WHERE code = ?", query)
+		assert.Equal(t, []any{"ABC"}, args)
+	})
+}
+
+func TestIdent(t *testing.T) {
+	t.Parallel()
+
+	const queryTemplate = `SELECT {{ ident .column }} FROM {{ .table | ident }} WHERE id = {{ .id }}`
+
+	testCases := []struct {
+		name          string
+		dialect       dialect.Dialect
+		expectedQuery string
+	}{
+		{name: "Postgres", dialect: dialect.Postgres, expectedQuery: `SELECT "name" FROM "billing"."invoices" WHERE id = $1`},
+		{name: "MySQL", dialect: dialect.MySQL, expectedQuery: "SELECT `name` FROM `billing`.`invoices` WHERE id = $1"},
+		{name: "SQLServer", dialect: dialect.SQLServer, expectedQuery: `SELECT [name] FROM [billing].[invoices] WHERE id = $1`},
+	}
+
+	for _, tc := range testCases {
+		t.Run("Success quoting allowed identifiers with "+tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := ContextWithIdentifiers(context.Background(), "name", "billing.invoices")
+
+			query, args, err := New(WithDialect(tc.dialect)).Parse(ctx, queryTemplate, map[string]any{"column": "name", "table": "billing.invoices", "id": 1}, tqla.Dollar)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedQuery, query)
+			assert.Equal(t, []any{1}, args)
+		})
+	}
+
+	t.Run("Failed quoting an identifier that is not allowed", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := ContextWithIdentifiers(context.Background(), "name")
+
+		_, _, err := New().Parse(ctx, queryTemplate, map[string]any{"column": "name", "table": "users; DROP TABLE users", "id": 1}, tqla.Dollar)
+		assert.ErrorIs(t, err, ErrIdentifierNotAllowed)
+	})
+}
+
+func TestValues(t *testing.T) {
+	t.Parallel()
+
+	const queryTemplate = `INSERT INTO users {{ values .users }} RETURNING {{ .returning }}`
+
+	type Audit struct {
+		CreatedBy string
+	}
+	type user struct {
+		Audit
+		ID       int64  `fayl:"id"`
+		Name     string `fayl:"name"`
+		Password string `fayl:"-"`
+	}
+
+	t.Run("Success rendering values from structs", func(t *testing.T) {
+		t.Parallel()
+
+		users := []user{{ID: 1, Name: "john", Audit: Audit{CreatedBy: "admin"}}, {ID: 2, Name: "jane"}}
+
+		query, args, err := New().Parse(context.Background(), queryTemplate, map[string]any{"users": users, "returning": 1}, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO users ("created_by", "id", "name") VALUES ($1, $2, $3), ($4, $5, $6) RETURNING $7`, query)
+		assert.Equal(t, []any{"admin", int64(1), "john", "", int64(2), "jane", 1}, args)
+	})
+
+	t.Run("Success rendering a slice column as a single value", func(t *testing.T) {
+		t.Parallel()
+
+		type post struct {
+			ID   int64    `fayl:"id"`
+			Tags []string `fayl:"tags"`
+		}
+
+		posts := []post{{ID: 1, Tags: []string{"go", "sql"}}}
+
+		query, args, err := New().Parse(context.Background(), `INSERT INTO posts {{ values .posts }}`, map[string]any{"posts": posts}, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO posts ("id", "tags") VALUES ($1, $2)`, query)
+		assert.Equal(t, []any{int64(1), []string{"go", "sql"}}, args)
+
+		// the slice params of the rest of the query are still expanded
+		query, args, err = New().Parse(context.Background(), `WITH d AS (DELETE FROM posts WHERE id IN ({{ .ids }})) INSERT INTO posts {{ values .posts }}`, map[string]any{"posts": posts, "ids": []int{1, 2}}, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, `WITH d AS (DELETE FROM posts WHERE id IN ($1, $2)) INSERT INTO posts ("id", "tags") VALUES ($3, $4)`, query)
+		assert.Equal(t, []any{1, 2, int64(1), []string{"go", "sql"}}, args)
+	})
+
+	t.Run("Success rendering values from maps", func(t *testing.T) {
+		t.Parallel()
+
+		users := []map[string]any{{"name": "john", "id": 1}, {"id": 2, "name": "jane"}}
+
+		ctx := ContextWithIdentifiers(context.Background(), "id", "name")
+
+		query, args, err := New(WithDialect(dialect.MySQL)).Parse(ctx, queryTemplate, map[string]any{"users": users, "returning": 1}, tqla.Question)
+		assert.NoError(t, err)
+		assert.Equal(t, "INSERT INTO users (`id`, `name`) VALUES (?, ?), (?, ?) RETURNING ?", query)
+		assert.Equal(t, []any{1, "john", 2, "jane", 1}, args)
+	})
+
+	t.Run("Failed rendering values from maps with a column not allowed", func(t *testing.T) {
+		t.Parallel()
+
+		users := []map[string]any{{"id": 1, "name": "john", "is_admin": true}}
+		ctx := ContextWithIdentifiers(context.Background(), "id", "name")
+
+		_, _, err := New().Parse(ctx, queryTemplate, map[string]any{"users": users, "returning": 1}, tqla.Dollar)
+		assert.ErrorIs(t, err, ErrIdentifierNotAllowed)
+		assert.ErrorContains(t, err, `identifier "is_admin"`)
+	})
+
+	t.Run("Failed rendering values from maps with different columns", func(t *testing.T) {
+		t.Parallel()
+
+		users := []map[string]any{{"id": 1, "name": "john"}, {"id": 2, "email": "jane@example.com"}}
+
+		_, _, err := New().Parse(context.Background(), queryTemplate, map[string]any{"users": users, "returning": 1}, tqla.Dollar)
+		assert.ErrorContains(t, err, "row 1: map has no column name")
+	})
+
+	t.Run("Failed rendering values from an empty slice", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := New().Parse(context.Background(), queryTemplate, map[string]any{"users": []user{}, "returning": 1}, tqla.Dollar)
+		assert.ErrorIs(t, err, ErrEmptySlice)
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	t.Parallel()
+
+	const queryTemplate = `{{ upsert "users" .users "id" "name, email" }}`
+
+	users := []map[string]any{{"id": 1, "name": "john", "email": "john@example.com"}}
+	ctx := ContextWithIdentifiers(context.Background(), "users", "id", "name", "email")
+
+	testCases := []struct {
+		name          string
+		dialect       dialect.Dialect
+		placeholder   Placeholder
+		expectedQuery string
+	}{
+		{
+			name:          "Postgres",
+			dialect:       dialect.Postgres,
+			placeholder:   tqla.Dollar,
+			expectedQuery: `INSERT INTO "users" ("email", "id", "name") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name", "email" = EXCLUDED."email"`,
+		},
+		{
+			name:          "MySQL",
+			dialect:       dialect.MySQL,
+			placeholder:   tqla.Question,
+			expectedQuery: "INSERT INTO `users` (`email`, `id`, `name`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `email` = VALUES(`email`)",
+		},
+		{
+			name:        "SQLServer",
+			dialect:     dialect.SQLServer,
+			placeholder: tqla.AtP,
+			expectedQuery: "MERGE INTO [users] AS t USING (VALUES (@p1, @p2, @p3)) AS s ([email], [id], [name]) ON (t.[id] = s.[id]) " +
+				"WHEN MATCHED THEN UPDATE SET t.[name] = s.[name], t.[email] = s.[email] " +
+				"WHEN NOT MATCHED THEN INSERT ([email], [id], [name]) VALUES (s.[email], s.[id], s.[name]);",
+		},
+		{
+			name:        "Oracle",
+			dialect:     dialect.Oracle,
+			placeholder: tqla.Colon,
+			expectedQuery: `MERGE INTO "users" t USING (SELECT :1 "email", :2 "id", :3 "name" FROM dual) s ON (t."id" = s."id") ` +
+				`WHEN MATCHED THEN UPDATE SET t."name" = s."name", t."email" = s."email" ` +
+				`WHEN NOT MATCHED THEN INSERT ("email", "id", "name") VALUES (s."email", s."id", s."name")`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run("Success rendering an upsert with "+tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, args, err := New(WithDialect(tc.dialect)).Parse(ctx, queryTemplate, map[string]any{"users": users}, tc.placeholder)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedQuery, query)
+			assert.Equal(t, []any{"john@example.com", 1, "john"}, args)
+		})
+	}
+
+	t.Run("Success rendering an upsert without update columns", func(t *testing.T) {
+		t.Parallel()
+
+		query, _, err := New(WithDialect(dialect.Postgres)).Parse(ctx, `{{ upsert "users" .users "id" "" }}`, map[string]any{"users": users}, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO "users" ("email", "id", "name") VALUES ($1, $2, $3) ON CONFLICT ("id") DO NOTHING`, query)
+	})
+
+	t.Run("Failed rendering an upsert with an unknown column", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := New(WithDialect(dialect.Postgres)).Parse(ctx, `{{ upsert "users" .users "id" "password" }}`, map[string]any{"users": users}, tqla.Dollar)
+		assert.ErrorContains(t, err, "unknown column password")
+	})
+
+	t.Run("Failed rendering an upsert without dialect", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := New().Parse(ctx, queryTemplate, map[string]any{"users": users}, tqla.Dollar)
+		assert.ErrorIs(t, err, ErrUnsupportedDialect)
+	})
+
+	t.Run("Success rendering an upsert of structs with an allowed table only", func(t *testing.T) {
+		t.Parallel()
+
+		type user struct {
+			ID   int    `fayl:"id"`
+			Name string `fayl:"name"`
+		}
+
+		query, _, err := New(WithDialect(dialect.Postgres)).Parse(ContextWithIdentifiers(context.Background(), "users"), `{{ upsert "users" .users "id" "name" }}`, map[string]any{"users": []user{{ID: 1, Name: "john"}}}, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, `INSERT INTO "users" ("id", "name") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`, query)
+	})
+
+	t.Run("Failed rendering an upsert into a table not allowed", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := New(WithDialect(dialect.Postgres)).Parse(context.Background(), queryTemplate, map[string]any{"users": users}, tqla.Dollar)
+		assert.ErrorIs(t, err, ErrIdentifierNotAllowed)
+		assert.ErrorContains(t, err, `identifier "users"`)
+	})
+
+	t.Run("Failed rendering an upsert of a map column not allowed", func(t *testing.T) {
+		t.Parallel()
+
+		rows := []map[string]any{{"id": 1, "name": "john", "email": "john@example.com", "is_admin": true}}
+
+		_, _, err := New(WithDialect(dialect.Postgres)).Parse(ctx, queryTemplate, map[string]any{"users": rows}, tqla.Dollar)
+		assert.ErrorIs(t, err, ErrIdentifierNotAllowed)
+		assert.ErrorContains(t, err, `identifier "is_admin"`)
+	})
+}
+
+func TestTemplateParams(t *testing.T) {
+	t.Parallel()
+
+	ps := New()
+	assert.NoError(t, ps.AddPartial("tenant", "tenant_id = {{ .tenant_id }}"))
+
+	tmpl, err := ps.Compile("user.ListUsers", `
+		SELECT * FROM users
+		WHERE {{ template "tenant" . }}
+		{{ if .name }}AND name = {{ .name }}{{ end }}
+		{{ range $i, $status := .statuses }}{{ if $i }} OR {{ end }}status = {{ $status }} AND role = {{ $.role }}{{ end }}
+		{{ with .filter }}AND {{ .column }} = 1{{ end }}
+		LIMIT {{ add .limit 1 }}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"filter", "limit", "name", "role", "statuses", "tenant_id"}, tmpl.Params())
+}
+
+func TestNamed(t *testing.T) {
+	t.Parallel()
+
+	const query = `SELECT id::text, data ? 'key' FROM users WHERE email = :email AND id IN (:ids) AND note = ':skipped' -- :ignored`
+
+	data := map[string]any{"email": "john@example.com", "ids": []int{1, 2}}
+
+	t.Run("Success binding named params with dollar placeholders", func(t *testing.T) {
+		t.Parallel()
+
+		query, args, err := NewNamed().Parse(context.Background(), query, data, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, `SELECT id::text, data ? 'key' FROM users WHERE email = $1 AND id IN ($2, $3) AND note = ':skipped' -- :ignored`, query)
+		assert.Equal(t, []any{"john@example.com", 1, 2}, args)
+	})
+
+	t.Run("Success binding named params with question placeholders", func(t *testing.T) {
+		t.Parallel()
+
+		query, args, err := NewNamed().Parse(context.Background(), "SELECT '?' FROM users WHERE email = :email", data, tqla.Question)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT '?' FROM users WHERE email = ?", query)
+		assert.Equal(t, []any{"john@example.com"}, args)
+	})
+
+	t.Run("Failed binding a missing named param", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := NewNamed().Parse(context.Background(), "SELECT * FROM users WHERE name = :name", data, tqla.Dollar)
+		assert.EqualError(t, err, "failed to parse query: missing param name")
+	})
+}
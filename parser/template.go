@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"context"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/redhajuanda/fayl/dialect"
+
+	"github.com/pkg/errors"
+)
+
+// sqlParserFunc is the name of the template function that replaces every interpolated value with a placeholder.
+// It is the same name tqla uses, so templates behave the same whether they are compiled here or by tqla.
+const sqlParserFunc = "_sql_parser_"
+
+// rawFuncs are the template functions whose output is written into the query as is instead of being bound as a parameter.
+// They are responsible for producing safe SQL.
+var rawFuncs = map[string]bool{
+	identFunc:  true,
+	valuesFunc: true,
+	upsertFunc: true,
+}
+
+// Template is a query template compiled once and executed many times.
+// It is safe for concurrent use.
+type Template struct {
+	name       string
+	tmpl       *template.Template
+	emptySlice EmptySlicePolicy
+	dialect    dialect.Dialect
+	params     []string
+}
+
+// Compile parses the given query template and prepares it to be executed.
+// Every action of the template is rewritten to pass its value to the placeholder function,
+// so the values end up in the args slice instead of the query text.
+// The partials added to the parser are available to the template.
+func (p *parser) Compile(name, queryTemplate string) (*Template, error) {
+
+	tmpl := template.New(name).Funcs(p.parseFuncs())
+
+	// add a copy of the partials, so formatting them does not modify the trees shared with other templates
+	for _, partial := range p.loadPartials() {
+		for _, t := range partial.Templates() {
+			if t.Tree == nil || t.Name() == name {
+				continue
+			}
+			if _, err := tmpl.AddParseTree(t.Name(), t.Tree.Copy()); err != nil {
+				return nil, errors.Wrapf(err, "failed to add partial %s", t.Name())
+			}
+		}
+	}
+
+	tmpl, err := tmpl.Parse(queryTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse query")
+	}
+
+	formatTemplate(tmpl)
+
+	return &Template{
+		name:       name,
+		tmpl:       tmpl,
+		emptySlice: p.emptySlice,
+		dialect:    p.dialect,
+		params:     templateParams(tmpl),
+	}, nil
+
+}
+
+// parseFuncs returns the functions known when a template is parsed:
+// the functions of the parser, and stubs of the functions bound to each execution.
+func (p *parser) parseFuncs() template.FuncMap {
+
+	funcs := make(template.FuncMap, len(p.funcs)+4)
+	for name, fn := range p.funcs {
+		funcs[name] = fn
+	}
+	funcs[sqlParserFunc] = func(any) string { return "?" }
+	funcs[identFunc] = func(string) (string, error) { return "", nil }
+	funcs[valuesFunc] = func(any) (string, error) { return "", nil }
+	funcs[upsertFunc] = func(string, any, any, any) (string, error) { return "", nil }
+
+	return funcs
+
+}
+
+// Name returns the name of the template.
+func (t *Template) Name() string {
+	return t.name
+}
+
+// Execute executes the template with the given data and returns the query and its args.
+// Slice args are expanded into one placeholder per element before the placeholders are formatted.
+// The ident function only quotes the identifiers allowed in ctx, see ContextWithIdentifiers.
+// The values and upsert functions bind the value of every column of every row, in the order they appear in the query.
+func (t *Template) Execute(ctx context.Context, data map[string]any, placeholder Placeholder) (string, []any, error) {
+
+	// clone the template to bind the placeholder function to the args of this execution only,
+	// the parse trees are shared so this is much cheaper than parsing the template again
+	tmpl, err := t.tmpl.Clone()
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to clone query template")
+	}
+
+	var (
+		args []any
+		sb   strings.Builder
+	)
+
+	tmpl.Funcs(template.FuncMap{
+		sqlParserFunc: func(arg any) string {
+			args = append(args, arg)
+			return "?"
+		},
+		identFunc:  t.ident(identifiersFromContext(ctx)),
+		valuesFunc: t.values(identifiersFromContext(ctx), &args),
+		upsertFunc: t.upsert(identifiersFromContext(ctx), &args),
+	})
+
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", nil, errors.Wrap(err, "failed to compile query")
+	}
+
+	// interpolate query
+	query, args, err := interpolateQuery(sb.String(), args, t.emptySlice)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, err = placeholder.Format(query)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to compile query")
+	}
+
+	return strings.TrimSpace(query), args, nil
+
+}
+
+// formatTemplate rewrites all the templates defined in t, see formatNode.
+// It is adapted from github.com/VauntDev/tqla.
+func formatTemplate(t *template.Template) {
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil && tmpl.Tree.Root != nil {
+			formatNode(tmpl.Tree, tmpl.Tree.Root)
+		}
+	}
+
+}
+
+// formatNode appends the placeholder function to every pipeline that outputs a value,
+// except the pipelines that end with a raw function.
+func formatNode(t *parse.Tree, n parse.Node) {
+
+	switch v := n.(type) {
+	case *parse.ActionNode:
+		formatNode(t, v.Pipe)
+	case *parse.IfNode:
+		formatNode(t, v.List)
+		formatNode(t, v.ElseList)
+	case *parse.RangeNode:
+		formatNode(t, v.List)
+		formatNode(t, v.ElseList)
+	case *parse.WithNode:
+		formatNode(t, v.List)
+		formatNode(t, v.ElseList)
+	case *parse.ListNode:
+		if v == nil {
+			return
+		}
+		for _, n := range v.Nodes {
+			formatNode(t, n)
+		}
+	case *parse.PipeNode:
+		// if the pipe sets variables then don't try to format it
+		if len(v.Decl) > 0 || len(v.Cmds) < 1 {
+			return
+		}
+		cmd := v.Cmds[len(v.Cmds)-1]
+		if len(cmd.Args) == 1 && cmd.Args[0].Type() == parse.NodeIdentifier && cmd.Args[0].(*parse.IdentifierNode).Ident == sqlParserFunc {
+			return
+		}
+		if len(cmd.Args) > 0 && cmd.Args[0].Type() == parse.NodeIdentifier && rawFuncs[cmd.Args[0].(*parse.IdentifierNode).Ident] {
+			return
+		}
+		v.Cmds = append(v.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Args:     []parse.Node{parse.NewIdentifier(sqlParserFunc).SetTree(t).SetPos(cmd.Pos)},
+		})
+	}
+
+}
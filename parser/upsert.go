@@ -0,0 +1,258 @@
+package parser
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/redhajuanda/fayl/dialect"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUnsupportedDialect is returned when a template function cannot render SQL for the dialect of the parser.
+var ErrUnsupportedDialect = errors.New("unsupported dialect")
+
+// upsertFunc is the name of the template function that renders an upsert statement.
+const upsertFunc = "upsert"
+
+// upsert returns the upsert template function of an execution.
+// The function renders a statement that inserts the given rows into table,
+// and updates the update columns of the rows that conflict on the conflict columns:
+//
+//	postgres, sqlite  INSERT INTO ... VALUES ... ON CONFLICT (...) DO UPDATE SET col = EXCLUDED.col
+//	mysql             INSERT INTO ... VALUES ... ON DUPLICATE KEY UPDATE col = VALUES(col)
+//	sqlserver         MERGE INTO ... USING (VALUES ...) ... WHEN MATCHED THEN UPDATE ... WHEN NOT MATCHED THEN INSERT ...;
+//	oracle            MERGE INTO ... USING (SELECT ... FROM dual UNION ALL ...) ... WHEN MATCHED THEN UPDATE ... WHEN NOT MATCHED THEN INSERT ...
+//
+// rows is a slice of structs or maps like for the values function.
+// conflict and update are column lists, either a comma separated string or a []string, and must be columns of the rows.
+// Without update column, the conflicting rows are left untouched.
+//
+// Like for the ident function, the table must be in the allowlist of the execution, see ContextWithIdentifiers.
+// So must the columns of map rows, which come from the data, whereas the columns of struct rows are defined by the code.
+func (t *Template) upsert(allowed []string, args *[]any) func(table string, rows, conflict, update any) (string, error) {
+
+	return func(table string, rows, conflict, update any) (string, error) {
+
+		if err := allowIdentifier(allowed, table); err != nil {
+			return "", errors.Wrap(err, "failed to render upsert table")
+		}
+
+		columns, values, err := rowValues(rows)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to render upsert")
+		}
+
+		if err := allowMapColumns(allowed, rows, columns); err != nil {
+			return "", errors.Wrap(err, "failed to render upsert columns")
+		}
+
+		conflictColumns, err := columnList(conflict, columns)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to render upsert conflict columns")
+		}
+		if len(conflictColumns) == 0 {
+			return "", errors.New("failed to render upsert: conflict columns are required")
+		}
+
+		updateColumns, err := columnList(update, columns)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to render upsert update columns")
+		}
+
+		var sb strings.Builder
+
+		switch t.dialect {
+		case dialect.Postgres, dialect.SQLite:
+			t.writeInsert(&sb, table, columns, values, args)
+			sb.WriteString(" ON CONFLICT (")
+			sb.WriteString(t.quoteColumns(conflictColumns))
+			sb.WriteString(")")
+			if len(updateColumns) == 0 {
+				sb.WriteString(" DO NOTHING")
+				break
+			}
+			sb.WriteString(" DO UPDATE SET ")
+			t.writeAssignments(&sb, updateColumns, "", "EXCLUDED.%s")
+		case dialect.MySQL:
+			t.writeInsert(&sb, table, columns, values, args)
+			sb.WriteString(" ON DUPLICATE KEY UPDATE ")
+			if len(updateColumns) == 0 {
+				// assigning a column to itself leaves the row untouched
+				t.writeAssignments(&sb, conflictColumns[:1], "", "%s")
+				break
+			}
+			t.writeAssignments(&sb, updateColumns, "", "VALUES(%s)")
+		case dialect.SQLServer:
+			sb.WriteString("MERGE INTO ")
+			sb.WriteString(t.dialect.QuoteIdentifier(table))
+			sb.WriteString(" AS t USING (VALUES ")
+			writeRows(&sb, values, args)
+			sb.WriteString(") AS s (")
+			sb.WriteString(t.quoteColumns(columns))
+			sb.WriteString(")")
+			t.writeMerge(&sb, columns, conflictColumns, updateColumns)
+			sb.WriteString(";")
+		case dialect.Oracle:
+			sb.WriteString("MERGE INTO ")
+			sb.WriteString(t.dialect.QuoteIdentifier(table))
+			sb.WriteString(" t USING (")
+			for i, row := range values {
+				if i > 0 {
+					sb.WriteString(" UNION ALL ")
+				}
+				sb.WriteString("SELECT ")
+				for j, column := range columns {
+					if j > 0 {
+						sb.WriteString(", ")
+					}
+					sb.WriteString("? ")
+					sb.WriteString(t.dialect.QuoteIdentifier(column))
+				}
+				sb.WriteString(" FROM dual")
+				appendCells(args, row)
+			}
+			sb.WriteString(") s")
+			t.writeMerge(&sb, columns, conflictColumns, updateColumns)
+		default:
+			return "", errors.Wrapf(ErrUnsupportedDialect, "failed to render upsert for dialect %q", t.dialect)
+		}
+
+		return sb.String(), nil
+
+	}
+
+}
+
+// writeInsert writes an "INSERT INTO table (columns) VALUES ..." statement to sb and appends the values to args.
+func (t *Template) writeInsert(sb *strings.Builder, table string, columns []string, values [][]any, args *[]any) {
+
+	sb.WriteString("INSERT INTO ")
+	sb.WriteString(t.dialect.QuoteIdentifier(table))
+	sb.WriteString(" (")
+	sb.WriteString(t.quoteColumns(columns))
+	sb.WriteString(") VALUES ")
+	writeRows(sb, values, args)
+
+}
+
+// writeMerge writes the ON, WHEN MATCHED and WHEN NOT MATCHED clauses of a MERGE statement
+// whose target is aliased t and whose source is aliased s.
+func (t *Template) writeMerge(sb *strings.Builder, columns, conflictColumns, updateColumns []string) {
+
+	sb.WriteString(" ON (")
+	for i, column := range conflictColumns {
+		if i > 0 {
+			sb.WriteString(" AND ")
+		}
+		column = t.dialect.QuoteIdentifier(column)
+		sb.WriteString("t." + column + " = s." + column)
+	}
+	sb.WriteString(")")
+
+	if len(updateColumns) > 0 {
+		sb.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		t.writeAssignments(sb, updateColumns, "t.", "s.%s")
+	}
+
+	sb.WriteString(" WHEN NOT MATCHED THEN INSERT (")
+	sb.WriteString(t.quoteColumns(columns))
+	sb.WriteString(") VALUES (")
+	for i, column := range columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("s." + t.dialect.QuoteIdentifier(column))
+	}
+	sb.WriteString(")")
+
+}
+
+// writeAssignments writes a "col = value" list to sb, the value is the quoted column formatted with the given format.
+func (t *Template) writeAssignments(sb *strings.Builder, columns []string, prefix, format string) {
+
+	for i, column := range columns {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		column = t.dialect.QuoteIdentifier(column)
+		sb.WriteString(prefix + column + " = " + strings.ReplaceAll(format, "%s", column))
+	}
+
+}
+
+// columnList returns the columns of the given comma separated string or string slice.
+// Every column must be one of the given columns.
+func columnList(list any, columns []string) ([]string, error) {
+
+	var names []string
+
+	switch v := list.(type) {
+	case nil:
+	case string:
+		names = strings.Split(v, ",")
+	case []string:
+		names = v
+	default:
+		return nil, errors.Errorf("column list must be a string or a []string, got %T", list)
+	}
+
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(columns, name) {
+			return nil, errors.Errorf("unknown column %s", name)
+		}
+		result = append(result, name)
+	}
+
+	return result, nil
+
+}
+
+// allowMapColumns returns an error if the given rows have a map row and one of their columns is not in the allowlist
+// of the execution. The columns of map rows come from the data, whereas the columns of struct rows are defined by the code.
+func allowMapColumns(allowed []string, rows any, columns []string) error {
+
+	if !hasMapRow(rows) {
+		return nil
+	}
+
+	for _, column := range columns {
+		if err := allowIdentifier(allowed, column); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// hasMapRow reports whether one of the given rows is a map, whose columns come from the data.
+func hasMapRow(rows any) bool {
+
+	v := reflect.ValueOf(rows)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return false
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		for (row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface) && !row.IsNil() {
+			row = row.Elem()
+		}
+		if row.Kind() == reflect.Map {
+			return true
+		}
+	}
+
+	return false
+
+}
@@ -0,0 +1,254 @@
+package parser
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/redhajuanda/fayl/vars"
+
+	"github.com/georgysavva/scany/v2/dbscan"
+	"github.com/pkg/errors"
+)
+
+// valuesFunc is the name of the template function that renders the column list and the VALUES clause of a bulk insert.
+const valuesFunc = "values"
+
+// values returns the values template function of an execution.
+// The function renders "(col1, col2) VALUES (?, ?), (?, ?)" from a slice of structs or maps,
+// and appends the value of every column of every row to args.
+// The column names are quoted with the quoting of the dialect and written into the query as is,
+// so like for the ident function, the columns of map rows, which come from the data, must be in the allowlist
+// of the execution, see ContextWithIdentifiers. The columns of struct rows are defined by the code.
+func (t *Template) values(allowed []string, args *[]any) func(rows any) (string, error) {
+
+	return func(rows any) (string, error) {
+
+		columns, values, err := rowValues(rows)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to render values")
+		}
+
+		if err := allowMapColumns(allowed, rows, columns); err != nil {
+			return "", errors.Wrap(err, "failed to render values columns")
+		}
+
+		var sb strings.Builder
+
+		sb.WriteString("(")
+		sb.WriteString(t.quoteColumns(columns))
+		sb.WriteString(") VALUES ")
+
+		writeRows(&sb, values, args)
+
+		return sb.String(), nil
+
+	}
+
+}
+
+// rowCell is the value of a column of a row rendered by the values or upsert function.
+// It is bound as a single parameter even if it is a slice, e.g. a []string for a Postgres text[] column.
+type rowCell struct {
+	value any
+}
+
+// cellValue returns the value of the given arg, unwrapped if it is a row cell.
+func cellValue(arg any) any {
+
+	if cell, ok := arg.(rowCell); ok {
+		return cell.value
+	}
+	return arg
+
+}
+
+// appendCells appends the values of the given row to args as row cells.
+func appendCells(args *[]any, row []any) {
+
+	for _, value := range row {
+		*args = append(*args, rowCell{value})
+	}
+
+}
+
+// writeRows writes a "(?, ?), (?, ?)" list of rows to sb and appends their values to args.
+func writeRows(sb *strings.Builder, values [][]any, args *[]any) {
+
+	for i, row := range values {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for j := range row {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString("?")
+		}
+		sb.WriteString(")")
+		appendCells(args, row)
+	}
+
+}
+
+// quoteColumns quotes the given column names with the quoting of the dialect and joins them with commas.
+func (t *Template) quoteColumns(columns []string) string {
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = t.dialect.QuoteIdentifier(column)
+	}
+
+	return strings.Join(quoted, ", ")
+
+}
+
+// rowValues returns the columns and the values of the given rows.
+// rows must be a non-empty slice of structs or of maps with string keys.
+// The columns of a struct are its fields, named by their fayl tag or by their name in snake case like when scanning,
+// and the columns of a map are its keys, sorted alphabetically. Every row must have the same columns.
+func rowValues(rows any) ([]string, [][]any, error) {
+
+	v := reflect.ValueOf(rows)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, nil, errors.Errorf("rows must be a slice of structs or maps, got %T", rows)
+	}
+	if v.Len() == 0 {
+		return nil, nil, ErrEmptySlice
+	}
+
+	var (
+		columns []string
+		values  = make([][]any, 0, v.Len())
+	)
+
+	for i := 0; i < v.Len(); i++ {
+
+		row := v.Index(i)
+		for row.Kind() == reflect.Ptr || row.Kind() == reflect.Interface {
+			if row.IsNil() {
+				return nil, nil, errors.Errorf("row %d is nil", i)
+			}
+			row = row.Elem()
+		}
+
+		var (
+			rowColumns []string
+			rowCells   []any
+			err        error
+		)
+
+		switch row.Kind() {
+		case reflect.Struct:
+			rowColumns, rowCells = structValues(row)
+		case reflect.Map:
+			rowColumns, rowCells, err = mapValues(row, columns)
+		default:
+			err = errors.Errorf("rows must be a slice of structs or maps, got a row of type %s", row.Type())
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "row %d", i)
+		}
+
+		if i == 0 {
+			columns = rowColumns
+		} else if !slices.Equal(columns, rowColumns) {
+			return nil, nil, errors.Errorf("row %d has different columns than the first row", i)
+		}
+
+		values = append(values, rowCells)
+
+	}
+
+	if len(columns) == 0 {
+		return nil, nil, errors.New("rows have no column")
+	}
+	for _, column := range columns {
+		// a question mark would be mistaken for a placeholder
+		if strings.Contains(column, "?") {
+			return nil, nil, errors.Errorf("column %q must not contain a question mark", column)
+		}
+	}
+
+	return columns, values, nil
+
+}
+
+// structValues returns the columns and the values of the exported fields of the given struct.
+// The fields of an embedded struct without tag are columns of the struct itself.
+func structValues(v reflect.Value) ([]string, []any) {
+
+	var (
+		columns []string
+		values  []any
+	)
+
+	for i := 0; i < v.NumField(); i++ {
+
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get(vars.TagKey), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embeddedColumns, embeddedValues := structValues(v.Field(i))
+			columns = append(columns, embeddedColumns...)
+			values = append(values, embeddedValues...)
+			continue
+		}
+
+		if name == "" {
+			name = dbscan.SnakeCaseMapper(field.Name)
+		}
+
+		columns = append(columns, name)
+		values = append(values, v.Field(i).Interface())
+
+	}
+
+	return columns, values
+
+}
+
+// mapValues returns the columns and the values of the given map.
+// The columns are the keys of the map, sorted alphabetically unless the columns of a previous row are given.
+func mapValues(v reflect.Value, columns []string) ([]string, []any, error) {
+
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, nil, errors.Errorf("map keys must be strings, got %s", v.Type().Key())
+	}
+
+	if columns == nil {
+		columns = make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			columns = append(columns, key.String())
+		}
+		sort.Strings(columns)
+	}
+
+	if v.Len() != len(columns) {
+		return nil, nil, errors.New("map has different columns than the first row")
+	}
+
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		value := v.MapIndex(reflect.ValueOf(column).Convert(v.Type().Key()))
+		if !value.IsValid() {
+			return nil, nil, errors.Errorf("map has no column %s", column)
+		}
+		values[i] = value.Interface()
+	}
+
+	return columns, values, nil
+
+}
@@ -0,0 +1,40 @@
+package fayl
+
+import (
+	"github.com/redhajuanda/fayl/parser"
+
+	"github.com/pkg/errors"
+)
+
+// registry is the set of runners loaded from the query files, along with the parser that knows their partials.
+// It is never modified once built, reloading the query files swaps it as a whole.
+type registry struct {
+	runners map[string]query
+	parser  parser.Parser
+}
+
+// newParser returns the parser of the query templates, which makes the given partials available to every template.
+// It is the given custom parser if any, or a new builtin parser configured with opts.
+// The partials of a custom parser are replaced as a whole, so it holds the same partials after every reload.
+// It fails if there are partials but the parser does not support them.
+func newParser(custom parser.Parser, partials map[string]string, opts ...parser.Option) (parser.Parser, error) {
+
+	if custom == nil {
+		custom = parser.New(opts...)
+	}
+
+	ps, ok := custom.(parser.PartialSetter)
+	if !ok {
+		if len(partials) > 0 {
+			return nil, errors.New("the parser does not support partials")
+		}
+		return custom, nil
+	}
+
+	if err := ps.SetPartials(partials); err != nil {
+		return nil, err
+	}
+
+	return custom, nil
+
+}
@@ -18,7 +18,7 @@ import (
 )
 
 type responser struct {
-	rows            *sqlx.Rows
+	rows            *countingRows
 	mapScanFunc     func(r rower, dest map[string]any) error
 	jsonMarshalFunc func(v any) ([]byte, error)
 	kuysor          *kuysor.Result
@@ -175,30 +175,219 @@ func (r *responser) ScanMaps(dest *[]map[string]any) error {
 
 }
 
-// ScanWriter scans all rows of the result set into the provided writer
-// The destination must be a writer
-func (r *responser) ScanWriter(dest io.Writer) error {
+// ScanWriter streams all rows of the result set to the provided writer, one row at a time
+// The format of the output is set with the options, it defaults to a JSON array
+func (r *responser) ScanWriter(dest io.Writer, opts ...WriterOption) error {
 
 	r.log.Debug("Scanning into writer")
 
-	result := make([]map[string]any, 0)
+	if dest == nil {
+		return errors.New("destination cannot be nil")
+	}
+
+	defer r.rows.Close()
 
-	// Scan the rows into a slice of maps
-	err := r.ScanMaps(&result)
+	columns, err := r.rows.Columns()
 	if err != nil {
+		return errors.Wrap(err, "failed to get columns")
+	}
+
+	w, err := newRowWriter(dest, columns, r.jsonMarshalFunc, opts...)
+	if err != nil {
+		return err
+	}
+
+	// the cursor pagination trims the extra row of the page, so the page is scanned first, its size is bounded by the limit
+	if r.tabling != nil && r.tabling.Pagination != nil && r.tabling.Pagination.Type == "cursor" {
+
+		result := make([]map[string]any, 0)
+		if err := r.ScanMaps(&result); err != nil {
+			return err
+		}
+
+		for _, row := range result {
+			if err := w.writeRow(row); err != nil {
+				return err
+			}
+		}
+
+		return w.close()
+
+	}
+
+	for r.rows.Next() {
+
+		row := make(map[string]any, len(columns))
+
+		// Use the mapScanFunc to scan the row into the map
+		if err := r.mapScanFunc(r.rows, row); err != nil {
+			return err
+		}
+
+		if err := w.writeRow(row); err != nil {
+			return err
+		}
+
+	}
+
+	if err := r.rows.Err(); err != nil {
+		return errors.Wrap(err, "failed to iterate rows")
+	}
+
+	return w.close()
+
+}
+
+// ScanValue scans the single column of the single row of the result set into the provided value
+// The destination must be a pointer to a value of the type of the column
+func (r *responser) ScanValue(dest any) error {
+
+	r.log.Debug("Scanning into value")
+
+	if dest == nil || reflect.TypeOf(dest).Kind() != reflect.Ptr {
+		return errors.New("destination must be a pointer")
+	}
+
+	defer r.rows.Close()
+
+	if err := r.ensureSingleColumn(); err != nil {
 		return err
 	}
 
-	// Convert the result to JSON
-	jsonResult, err := r.jsonMarshalFunc(result)
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return errors.Wrap(err, "failed to scan value")
+		}
+		return sql.ErrNoRows
+	}
+
+	// Scan the column with the conversions of database/sql, so any type the driver supports can be used, e.g. time.Time
+	if err := r.rows.Scan(dest); err != nil {
+		return errors.Wrap(err, "failed to scan value")
+	}
+
+	if r.rows.Next() {
+		return errors.New("failed to scan value: expected 1 row, got more")
+	}
+
+	return errors.Wrap(r.rows.Err(), "failed to scan value")
+
+}
+
+// ScanValues scans the single column of all rows of the result set into the provided slice
+// The destination must be a pointer to a slice of the type of the column
+func (r *responser) ScanValues(dest any) error {
+
+	r.log.Debug("Scanning into slice of values")
+
+	// Ensure v is a pointer to a slice
+	sliceValue := reflect.ValueOf(dest)
+	if sliceValue.Kind() != reflect.Ptr || sliceValue.Elem().Kind() != reflect.Slice {
+		return errors.Errorf("destination must be a pointer to a slice")
+	}
+
+	defer r.rows.Close()
+
+	// the cursors of the cursor pagination are built from the order by columns of the rows, which a single value lacks
+	if r.tabling != nil && r.tabling.Pagination != nil && r.tabling.Pagination.Type == "cursor" {
+		return errors.New("cursor pagination is not supported when scanning values, use ScanStructs or ScanMaps instead")
+	}
+
+	if err := r.ensureSingleColumn(); err != nil {
+		return err
+	}
+
+	// Reset the slice, like ScanStructs does
+	slice := sliceValue.Elem()
+	slice.Set(slice.Slice(0, 0))
+
+	for r.rows.Next() {
+
+		value := reflect.New(slice.Type().Elem())
+		if err := r.rows.Scan(value.Interface()); err != nil {
+			return errors.Wrap(err, "failed to scan values")
+		}
+
+		slice.Set(reflect.Append(slice, value.Elem()))
+
+	}
+
+	if err := r.rows.Err(); err != nil {
+		return errors.Wrap(err, "failed to scan values")
+	}
+
+	if r.tabling != nil && r.tabling.Pagination != nil && r.tabling.Pagination.Type == "offset" {
+		r.tabling.Pagination.BuildResponseOffset(int(r.tabling.OffsetTotalData))
+	}
+
+	return nil
+
+}
+
+// ScanFunc calls fn for every row of the result set, scanning one row at a time
+// The scanning stops at the first error returned by fn
+func (r *responser) ScanFunc(fn func(row *Row) error) error {
+
+	r.log.Debug("Scanning with func")
+
+	defer r.rows.Close()
+
+	// the cursor pagination trims the extra row of the result, which requires the whole result
+	if r.tabling != nil && r.tabling.Pagination != nil && r.tabling.Pagination.Type == "cursor" {
+		return errors.New("cursor pagination is not supported when streaming rows, use ScanStructs or ScanMaps instead")
+	}
+
+	// initialize the dbscan API with the provided struct tag key and column separator
+	api, err := dbscan.NewAPI(
+		dbscan.WithStructTagKey(vars.TagKey),
+		dbscan.WithColumnSeparator("__"),
+	)
 	if err != nil {
-		return errors.Wrap(err, "failed to marshal result to JSON")
+		return errors.Wrap(err, "failed to create new API")
 	}
 
-	// Write the JSON to the writer
-	_, err = dest.Write(jsonResult)
+	columns, err := r.rows.Columns()
 	if err != nil {
-		return errors.Wrap(err, "failed to write JSON to writer")
+		return errors.Wrap(err, "failed to get columns")
+	}
+
+	// the row scanner caches the mapping of the columns to the struct fields between rows
+	row := &Row{
+		rows:        r.rows,
+		scanner:     api.NewRowScanner(r.rows),
+		mapScanFunc: r.mapScanFunc,
+		columns:     columns,
+	}
+
+	for r.rows.Next() {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	// the rows stop early with the error of the context when it is canceled
+	if err := r.rows.Err(); err != nil {
+		return errors.Wrap(err, "failed to iterate rows")
+	}
+
+	if r.tabling != nil && r.tabling.Pagination != nil && r.tabling.Pagination.Type == "offset" {
+		r.tabling.Pagination.BuildResponseOffset(int(r.tabling.OffsetTotalData))
+	}
+
+	return nil
+
+}
+
+// ensureSingleColumn returns an error if the result set does not have exactly one column
+func (r *responser) ensureSingleColumn() error {
+
+	columns, err := r.rows.Columns()
+	if err != nil {
+		return errors.Wrap(err, "failed to get columns")
+	}
+
+	if len(columns) != 1 {
+		return errors.Errorf("expected 1 column, got %d", len(columns))
 	}
 
 	return nil
@@ -215,3 +404,39 @@ func (r *responser) Close() error {
 	return nil
 
 }
+
+// countingRows counts the rows read from the wrapped rows.
+type countingRows struct {
+	*sqlx.Rows
+	count int64
+	// single makes the rows end after the first row and leaves them open when they are closed,
+	// so the rows a single row scanner did not read can be counted afterwards
+	single bool
+}
+
+// Next prepares the next row and counts it.
+func (c *countingRows) Next() bool {
+
+	if c.single && c.count > 0 {
+		return false
+	}
+
+	if !c.Rows.Next() {
+		return false
+	}
+
+	c.count++
+	return true
+
+}
+
+// Close closes the rows, unless they end after the first row.
+func (c *countingRows) Close() error {
+
+	if c.single {
+		return nil
+	}
+
+	return c.Rows.Close()
+
+}
@@ -2,8 +2,56 @@ package fayl
 
 import (
 	"database/sql"
+
+	"github.com/pkg/errors"
 )
 
 type ResultExec struct {
 	sql.Result
 }
+
+// batchResult is the result of a batch executed in several statements.
+type batchResult struct {
+	results []sql.Result
+}
+
+// LastInsertId returns the last insert id of the last statement.
+func (b *batchResult) LastInsertId() (int64, error) {
+
+	if len(b.results) == 0 {
+		return 0, nil
+	}
+	return b.results[len(b.results)-1].LastInsertId()
+
+}
+
+// RowsAffected returns the sum of the rows affected by every statement.
+func (b *batchResult) RowsAffected() (int64, error) {
+
+	var total int64
+	for _, result := range b.results {
+		n, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+
+	return total, nil
+
+}
+
+// scannedResult is the result of a statement whose returned rows were scanned, e.g. with a RETURNING clause.
+type scannedResult struct {
+	rowsAffected int64
+}
+
+// LastInsertId is not available for a scanned statement, the id should be scanned from its RETURNING or OUTPUT clause.
+func (s *scannedResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not available for a scanned statement, scan the id from its RETURNING or OUTPUT clause")
+}
+
+// RowsAffected returns the number of rows scanned.
+func (s *scannedResult) RowsAffected() (int64, error) {
+	return s.rowsAffected, nil
+}
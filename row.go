@@ -0,0 +1,72 @@
+package fayl
+
+import (
+	"reflect"
+
+	"github.com/georgysavva/scany/v2/dbscan"
+	"github.com/pkg/errors"
+)
+
+// Row is the current row of a result set streamed with ScanFunc.
+// It is only valid during the call of the callback it is passed to, the next row reuses it.
+type Row struct {
+	rows        *countingRows
+	scanner     *dbscan.RowScanner
+	mapScanFunc func(r rower, dest map[string]any) error
+	columns     []string
+}
+
+// Columns returns the column names of the row.
+func (r *Row) Columns() []string {
+
+	return r.columns
+
+}
+
+// Scan scans the row into dest, which must be a pointer.
+// A struct is scanned like ScanStruct, with the fayl tag and the "__" separator of nested structs,
+// a map[string]any is scanned like ScanMap, and any other type is the value of the single column like ScanValue.
+func (r *Row) Scan(dest any) error {
+
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("destination must be a non nil pointer")
+	}
+
+	// Scan into a map, replacing its content
+	if m, ok := dest.(*map[string]any); ok {
+		*m = make(map[string]any, len(r.columns))
+		return r.mapScanFunc(r.rows, *m)
+	}
+
+	// Scan the columns into the fields of the struct
+	if scansFields(v.Type().Elem()) {
+		if err := r.scanner.Scan(dest); err != nil {
+			return errors.Wrap(err, "failed to scan row")
+		}
+		return nil
+	}
+
+	if len(r.columns) != 1 {
+		return errors.Errorf("expected 1 column, got %d", len(r.columns))
+	}
+
+	// Scan the column with the conversions of database/sql, like ScanValue does
+	if err := r.rows.Scan(dest); err != nil {
+		return errors.Wrap(err, "failed to scan row")
+	}
+
+	return nil
+
+}
+
+// ScanMap scans the row into the provided map, like ScanMap does.
+func (r *Row) ScanMap(dest map[string]any) error {
+
+	if dest == nil {
+		return errors.New("destination cannot be nil")
+	}
+
+	return r.mapScanFunc(r.rows, dest)
+
+}
@@ -4,7 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"hash/fnv"
 	"io"
+	"maps"
+	"reflect"
+	"sort"
 
 	"github.com/redhajuanda/fayl/mapper"
 	"github.com/redhajuanda/fayl/parser"
@@ -38,6 +44,21 @@ type Runnerer interface {
 	// If no prefix is used, it will default to ascending order.
 	// Example: WithOrderBy("name", "-created_at") will order by name ascending and created_at descending.
 	WithOrderBy(orderBy ...string) Runnerer
+	// WithIdentifiers sets the identifiers the query template is allowed to quote with the ident function.
+	// ident writes a table, schema or column name into the query instead of binding it as a parameter,
+	// so any name that is not in this allowlist is rejected before the query reaches the database.
+	// The values and upsert functions check the columns of map rows against it too, and upsert its table.
+	// Example: WithIdentifiers("users", "archived_users") allows {{ ident .table }} to render "users" or "archived_users".
+	WithIdentifiers(identifiers ...string) Runnerer
+	// WithStrict enables or disables the strict mode for this runner, overriding Option.Strict.
+	// In strict mode, Exec and Query fail with a *ParamsError if a param referenced by the template is not set,
+	// or if a param is set but not referenced by the template.
+	WithStrict(strict bool) Runnerer
+	// WithBatch sets the param key to the given rows, a slice of structs or maps to insert with the values template function.
+	// Exec splits the rows into chunks, so every statement stays under the bind parameter limit of the dialect,
+	// and executes the chunks in the current transaction, or in a new one if the runner is not in a transaction.
+	// Example: WithBatch("users", users) with INSERT INTO users {{ values .users }}.
+	WithBatch(key string, rows any) Runnerer
 	// ScanMap initializes a runner with scanner map.
 	// dest is the destination of the scanner.
 	// It must be a map.
@@ -56,19 +77,59 @@ type Runnerer interface {
 	// It must be a pointer to a slice of structs.
 	ScanStructs(dest any) Runnerer
 	// ScanWriter initializes a runner with scanner writer.
-	// dest is the destination of the scanner.
-	ScanWriter(dest io.Writer) Runnerer
+	// dest is the destination of the scanner, the rows are streamed to it one at a time.
+	// The output is a JSON array by default, use WithFormat to write NDJSON, CSV or TSV.
+	ScanWriter(dest io.Writer, opts ...WriterOption) Runnerer
+	// ScanValue initializes a runner with scanner value.
+	// It scans the single column of the single row of the result, e.g. a count or an id.
+	// dest must be a pointer to a value of the type of the column, e.g. *int64.
+	ScanValue(dest any) Runnerer
+	// ScanValues initializes a runner with scanner values.
+	// It scans the single column of every row of the result.
+	// dest must be a pointer to a slice of the type of the column, e.g. *[]int64 or *[]uuid.UUID.
+	ScanValues(dest any) Runnerer
+	// ScanFunc initializes a runner with scanner func.
+	// It streams the result, fn is called for every row while the rows are open, so the result is never held in memory.
+	// The scanning stops at the first error returned by fn, which Query returns. See Iter for a range-over-func alternative.
+	ScanFunc(fn func(row *Row) error) Runnerer
 	// Exec executes the query and returns the result.
 	// It returns a ResultExec struct that contains the result of the execution.
+	// If a scanner is set, the rows returned by the statement, e.g. with a RETURNING or OUTPUT clause,
+	// are scanned into its destination and the rows affected of the result are the rows scanned.
+	// ScanStruct and ScanValue fail when the statement returns more than one row, it is only rolled back in a transaction, see Tx.Run.
 	Exec(ctx context.Context) (*ResultExec, error)
 	// Query executes the query and scans the result to the destination.
-	// The destination must be set using ScanMap, ScanMaps, ScanStruct, ScanStructs, or ScanWriter.
+	// The destination must be set using ScanMap, ScanMaps, ScanStruct, ScanStructs, ScanWriter, ScanValue, ScanValues or ScanFunc.
 	Query(ctx context.Context) error
+	// Build renders the query and its args like Query does, including the pagination and the order by,
+	// without executing it. It is meant for debugging, code review and snapshot tests.
+	Build(ctx context.Context) (query string, args []any, err error)
+	// BuildCount renders the query counting the total rows of the offset pagination and its args, without executing it.
+	// It fails if the runner has no offset pagination.
+	BuildCount(ctx context.Context) (query string, args []any, err error)
+	// BuildInline renders the query like Build, with its args inlined as SQL literals of the dialect,
+	// so it can be pasted into a SQL console. It is meant for debugging only: the result must never be executed.
+	BuildInline(ctx context.Context) (string, error)
+}
+
+// inlinePrefix starts the runner code of the runners created with RunSQL.
+const inlinePrefix = "<inline:"
+
+// inlineRunnerCode returns the runner code of a runner created with RunSQL, used in logs and errors.
+// It is derived from a hash of the query, so unrelated ad-hoc queries are told apart, e.g. <inline:9f86d081>.
+func inlineRunnerCode(sql string) string {
+
+	h := fnv.New32a()
+	h.Write([]byte(sql))
+
+	return fmt.Sprintf("%s%08x>", inlinePrefix, h.Sum32())
+
 }
 
 // // Runner is a struct that contains runner configs to be executed.
 type Runner struct {
 	runnerCode    string
+	sql           *string
 	params        map[string]any
 	client        *Client
 	log           logger.Logger
@@ -76,12 +137,25 @@ type Runner struct {
 	// // cacher        *Cacher
 	scanner *Scanner
 	tabling *Tabling
+	// identifiers is the allowlist of the ident template function
+	identifiers []string
+	// strict overrides the strict mode of the client when it is set
+	strict *bool
+	// batch is the rows param that Exec splits into chunks
+	batch *batch
 	// kuysor  *kuysor.Kuysor
 	errs []error
 }
 
+// batch is a rows param set with WithBatch.
+type batch struct {
+	key  string
+	rows any
+}
+
 type runnerParams struct {
 	runnerCode    string
+	sql           *string
 	client        *Client
 	log           logger.Logger
 	inTransaction bool
@@ -92,6 +166,7 @@ func newRunner(runnerParams runnerParams) *Runner {
 
 	return &Runner{
 		runnerCode:    runnerParams.runnerCode,
+		sql:           runnerParams.sql,
 		client:        runnerParams.client,
 		params:        make(map[string]any),
 		log:           runnerParams.log,
@@ -172,6 +247,15 @@ func (r *Runner) WithPagination(pagination *pagination.Pagination) Runnerer {
 	// }
 	// return r
 
+	// a nil pagination is not added to the query
+	if pagination == nil {
+		return r
+	}
+
+	if r.tabling == nil {
+		r.tabling = &Tabling{}
+	}
+
 	err := buildTabling(r.tabling, pagination)
 	if err != nil {
 		r.errs = append(r.errs, err)
@@ -199,6 +283,40 @@ func (r *Runner) WithOrderBy(orderBy ...string) Runnerer {
 
 }
 
+// WithIdentifiers sets the identifiers the query template is allowed to quote with the ident function.
+// ident writes a table, schema or column name into the query instead of binding it as a parameter,
+// so any name that is not in this allowlist is rejected before the query reaches the database.
+// The values and upsert functions check the columns of map rows against it too, and upsert its table.
+func (r *Runner) WithIdentifiers(identifiers ...string) Runnerer {
+
+	r.identifiers = append(r.identifiers, identifiers...)
+	return r
+
+}
+
+// WithStrict enables or disables the strict mode for this runner, overriding Option.Strict.
+// In strict mode, Exec and Query fail with a *ParamsError if a param referenced by the template is not set,
+// or if a param is set but not referenced by the template.
+func (r *Runner) WithStrict(strict bool) Runnerer {
+
+	r.strict = &strict
+	return r
+
+}
+
+// WithBatch sets the param key to the given rows, a slice of structs or maps to insert with the values template function.
+// Exec splits the rows into chunks, so every statement stays under the bind parameter limit of the dialect,
+// and executes the chunks in the current transaction, or in a new one if the runner is not in a transaction.
+func (r *Runner) WithBatch(key string, rows any) Runnerer {
+
+	r.batch = &batch{
+		key:  key,
+		rows: rows,
+	}
+	return r
+
+}
+
 // ScanMap initializes a runner with scanner map.
 // dest is the destination of the scanner.
 // It must be a map.
@@ -240,22 +358,80 @@ func (r *Runner) ScanStructs(dest any) Runnerer {
 }
 
 // ScanWriter initializes a runner with scanner writer.
-// dest is the destination of the scanner.
-// It must be a writer.
-func (r *Runner) ScanWriter(dest io.Writer) Runnerer {
+// dest is the destination of the scanner, the rows are streamed to it one at a time.
+// The output is a JSON array by default, use WithFormat to write NDJSON, CSV or TSV.
+func (r *Runner) ScanWriter(dest io.Writer, opts ...WriterOption) Runnerer {
+
+	r.scanner = newScanner(scannerWriter, &writer{dest: dest, opts: opts})
+	return r
+
+}
+
+// ScanValue initializes a runner with scanner value.
+// It scans the single column of the single row of the result.
+// dest must be a pointer to a value of the type of the column.
+func (r *Runner) ScanValue(dest any) Runnerer {
+
+	r.scanner = newScanner(scannerValue, dest)
+	return r
+
+}
+
+// ScanValues initializes a runner with scanner values.
+// It scans the single column of every row of the result.
+// dest must be a pointer to a slice of the type of the column.
+func (r *Runner) ScanValues(dest any) Runnerer {
+
+	r.scanner = newScanner(scannerValues, dest)
+	return r
+
+}
+
+// ScanFunc initializes a runner with scanner func.
+// fn is called for every row of the result while the rows are open.
+// The scanning stops at the first error returned by fn.
+func (r *Runner) ScanFunc(fn func(row *Row) error) Runnerer {
 
-	r.scanner = newScanner(scannerWriter, dest)
+	r.scanner = newScanner(scannerFunc, fn)
 	return r
 
 }
 
 // Exec executes the query and returns the result.
+// If a scanner is set, the rows returned by the statement are scanned into its destination.
 func (r *Runner) Exec(ctx context.Context) (*ResultExec, error) {
 
-	var (
-		ps     = parser.New()
-		result sql.Result
-	)
+	q, err := r.query()
+	if err != nil {
+		return nil, err
+	}
+
+	if q.metadata.ReadOnly {
+		return nil, errors.Wrapf(ErrReadOnlyRunner, "failed to execute %s", r.runnerCode)
+	}
+
+	ctx, cancel := r.applyMetadata(ctx, q.metadata)
+	defer cancel()
+
+	if r.batch != nil {
+		return r.execBatch(ctx, q)
+	}
+
+	result, err := r.exec(ctx, q, r.inTransaction)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResultExec{
+		result,
+	}, nil
+
+}
+
+// exec parses the given query and executes it, in the transaction of ctx if inTransaction is true.
+func (r *Runner) exec(ctx context.Context, q query, inTransaction bool) (sql.Result, error) {
+
+	var result sql.Result
 
 	r.log.WithContext(ctx).WithParams(map[string]any{
 		"runner_code": r.runnerCode,
@@ -264,18 +440,19 @@ func (r *Runner) Exec(ctx context.Context) (*ResultExec, error) {
 	}).Debug("Parsing query")
 
 	// parse query
-	query, parameters, err := ps.Parse(ctx, r.client.runners[r.runnerCode], r.params, r.client.placeholder)
+	query, parameters, err := r.parse(ctx, q)
 	if err != nil {
 		return nil, err
 	}
 
-	if r.inTransaction {
+	// a statement with a RETURNING or OUTPUT clause is scanned like a query
+	if r.scanner != nil && r.scanner.scannerType != noScanner {
+		return r.execScan(ctx, query, parameters, inTransaction)
+	}
 
-		r.log.WithContext(ctx).WithParams(map[string]any{
-			"runner_code": r.runnerCode,
-			"query":       query,
-			"params":      parameters,
-		}).Info("Executing query in transaction")
+	if inTransaction {
+
+		r.log.WithContext(ctx).WithParams(r.queryLogParams(query, parameters)).Info("Executing query in transaction")
 
 		// if in transaction, use the transaction context
 		tx, err := r.client.db.getTx(ctx)
@@ -291,11 +468,7 @@ func (r *Runner) Exec(ctx context.Context) (*ResultExec, error) {
 
 	} else {
 
-		r.log.WithContext(ctx).WithParams(map[string]any{
-			"runner_code": r.runnerCode,
-			"query":       query,
-			"params":      parameters,
-		}).Info("Executing query")
+		r.log.WithContext(ctx).WithParams(r.queryLogParams(query, parameters)).Info("Executing query")
 
 		// execute query
 		result, err = r.client.db.ExecContext(ctx, query, parameters...)
@@ -304,57 +477,281 @@ func (r *Runner) Exec(ctx context.Context) (*ResultExec, error) {
 		}
 	}
 
-	return &ResultExec{
-		result,
+	return result, nil
+
+}
+
+// execScan executes the given statement and scans the rows it returns, e.g. with a RETURNING or OUTPUT clause,
+// into the destination of the scanner. The rows affected of the result are the rows returned.
+// ScanMap scans the first row, the other rows are only counted.
+// ScanStruct and ScanValue fail when the statement returns more than one row, after it has been executed:
+// outside a transaction it is already committed, run it with Tx.Run to roll it back.
+func (r *Runner) execScan(ctx context.Context, query string, parameters []any, inTransaction bool) (sql.Result, error) {
+
+	var rows *sqlx.Rows
+
+	if inTransaction {
+
+		r.log.WithContext(ctx).WithParams(r.queryLogParams(query, parameters)).Info("Executing query in transaction")
+
+		// if in transaction, use the transaction context
+		tx, err := r.client.db.getTx(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get transaction")
+		}
+
+		// execute query
+		rows, err = tx.QueryxContext(ctx, query, parameters...)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to execute query in transaction")
+		}
+
+	} else {
+
+		r.log.WithContext(ctx).WithParams(r.queryLogParams(query, parameters)).Info("Executing query")
+
+		// execute query
+		var err error
+		rows, err = r.client.db.QueryxContext(ctx, query, parameters...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	defer rows.Close()
+
+	// a single row scanner only sees the first row, so the remaining rows can be counted afterwards
+	single := r.scansSingleRow() || r.scanner.scannerType == scannerMap
+
+	responser := &responser{
+		rows:        &countingRows{Rows: rows, single: single},
+		mapScanFunc: MapScan,
+		jsonMarshalFunc: func(v interface{}) ([]byte, error) {
+			return json.Marshal(v)
+		},
+		log: r.log,
+	}
+
+	// scan result
+	err := r.scan(ctx, responser)
+	if err != nil {
+		return nil, err
+	}
+
+	// drain and count the rows the scanner did not read
+	responser.rows.single = false
+	for responser.rows.Next() {
+	}
+	if err := responser.rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read returned rows")
+	}
+
+	if responser.rows.count > 1 && r.scansSingleRow() {
+		return nil, errors.Errorf("failed to scan returned rows: expected 1 row, got %d", responser.rows.count)
+	}
+
+	return &scannedResult{
+		rowsAffected: responser.rows.count,
 	}, nil
 
 }
 
+// scansSingleRow reports whether the scanner of the runner fails when the result has more than one row.
+func (r *Runner) scansSingleRow() bool {
+
+	return r.scanner != nil && (r.scanner.scannerType == scannerStruct || r.scanner.scannerType == scannerValue)
+
+}
+
+// execBatch executes the given query once per chunk of the batch rows,
+// so every statement stays under the bind parameter limit of the dialect.
+// The chunks are executed in the current transaction, or in a new one if the runner is not in a transaction,
+// and the rows affected by every chunk are summed up in the result.
+func (r *Runner) execBatch(ctx context.Context, q query) (*ResultExec, error) {
+
+	rows := reflect.ValueOf(r.batch.rows)
+	for rows.Kind() == reflect.Ptr && !rows.IsNil() {
+		rows = rows.Elem()
+	}
+	if rows.Kind() != reflect.Slice {
+		return nil, errors.Errorf("batch %s must be a slice of structs or maps, got %T", r.batch.key, r.batch.rows)
+	}
+
+	size, err := r.batchSize(ctx, q, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// a single chunk is executed like any other query
+	if size >= rows.Len() {
+		r.setBatchRows(r.batch.rows)
+		result, err := r.exec(ctx, q, r.inTransaction)
+		if err != nil {
+			return nil, err
+		}
+		return &ResultExec{result}, nil
+	}
+
+	// the rows returned by every chunk are appended to the destination, which requires a slice scanner
+	if r.scanner != nil {
+		switch r.scanner.scannerType {
+		case noScanner, scannerMaps, scannerStructs, scannerValues, scannerFunc:
+		default:
+			return nil, errors.Errorf("batch %s is executed in several statements, its returned rows can only be scanned with ScanMaps, ScanStructs, ScanValues or ScanFunc", r.batch.key)
+		}
+	}
+
+	execChunks := func(ctx context.Context) (*batchResult, error) {
+
+		result := &batchResult{}
+
+		for start := 0; start < rows.Len(); start += size {
+
+			end := min(start+size, rows.Len())
+
+			r.setBatchRows(rows.Slice(start, end).Interface())
+			res, err := r.execChunk(ctx, q)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to execute batch rows %d to %d", start, end-1)
+			}
+
+			result.results = append(result.results, res)
+
+		}
+
+		return result, nil
+
+	}
+
+	if r.inTransaction {
+		result, err := execChunks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &ResultExec{result}, nil
+	}
+
+	out, err := r.client.WithTransaction(ctx, func(ctx context.Context, _ *Tx) (any, error) {
+		return execChunks(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResultExec{out.(*batchResult)}, nil
+
+}
+
+// execChunk executes a chunk of a batch in the transaction of ctx.
+// ScanStructs and ScanValues replace the content of their destination, so the rows returned by the chunk
+// are scanned into a new slice and appended to the destination instead.
+func (r *Runner) execChunk(ctx context.Context, q query) (sql.Result, error) {
+
+	if r.scanner == nil || (r.scanner.scannerType != scannerStructs && r.scanner.scannerType != scannerValues) {
+		return r.exec(ctx, q, true)
+	}
+
+	dest := r.scanner.dest
+	defer func() { r.scanner.dest = dest }()
+
+	slice := reflect.ValueOf(dest)
+	if slice.Kind() != reflect.Ptr || slice.Elem().Kind() != reflect.Slice {
+		return nil, errors.New("destination must be a pointer to a slice")
+	}
+
+	chunk := reflect.New(slice.Elem().Type())
+	r.scanner.dest = chunk.Interface()
+
+	result, err := r.exec(ctx, q, true)
+	if err != nil {
+		return nil, err
+	}
+
+	slice.Elem().Set(reflect.AppendSlice(slice.Elem(), chunk.Elem()))
+
+	return result, nil
+
+}
+
+// batchSize returns the number of batch rows a single statement can hold under the bind parameter limit of the dialect.
+// It renders the query with one and two rows to count the bind parameters of a row and of the rest of the query.
+func (r *Runner) batchSize(ctx context.Context, q query, rows reflect.Value) (int, error) {
+
+	if rows.Len() < 2 {
+		return rows.Len(), nil
+	}
+
+	count := func(n int) (int, error) {
+		r.setBatchRows(rows.Slice(0, n).Interface())
+		_, args, err := r.parse(ctx, q)
+		return len(args), err
+	}
+
+	one, err := count(1)
+	if err != nil {
+		return 0, err
+	}
+	two, err := count(2)
+	if err != nil {
+		return 0, err
+	}
+
+	// the rows do not add any bind parameter, they do not need to be split
+	perRow := two - one
+	if perRow <= 0 {
+		return rows.Len(), nil
+	}
+
+	maxParams := r.client.dialect.MaxParams()
+	size := (maxParams - (one - perRow)) / perRow
+	if size < 1 {
+		return 0, errors.Errorf("batch %s: a single row needs more than %d bind parameters", r.batch.key, maxParams)
+	}
+
+	return size, nil
+
+}
+
+// setBatchRows sets the batch param to the given rows in a copy of the params,
+// so the map given to WithParams is not modified.
+func (r *Runner) setBatchRows(rows any) {
+
+	params := make(map[string]any, len(r.params)+1)
+	maps.Copy(params, r.params)
+	params[r.batch.key] = rows
+
+	r.params = params
+
+}
+
 // Query executes the query and scans the result to the destination.
 func (r *Runner) Query(ctx context.Context) error {
 
 	var (
-		ps              = parser.New()
-		rows            *sqlx.Rows
-		totalData       int64
-		queryFinal      string
-		parametersFinal []any
+		rows      *sqlx.Rows
+		totalData int64
 	)
 
-	r.log.WithContext(ctx).WithParams(map[string]any{
-		"runner_code": r.runnerCode,
-		"params":      r.params,
-		"placeholder": r.client.placeholder,
-	}).Debug("Parsing query")
-
-	// parse query
-	queryParsed, parametersParsed, err := ps.Parse(ctx, r.client.runners[r.runnerCode], r.params, r.client.placeholder)
+	q, err := r.query()
 	if err != nil {
 		return err
 	}
 
-	// build pagination cursor if pagination is set
-	// rs, err := r.buildTabling(ctx, queryParsed, parametersParsed...)
-	// if err != nil {
-	// 	return errors.Wrap(err, "failed to build pagination cursor")
-	// }
+	if err := r.checkCardinality(q.metadata.Cardinality); err != nil {
+		return err
+	}
 
-	rs, err := processTabling(ctx, r.client, r.tabling, queryParsed, parametersParsed...)
+	ctx, cancel := r.applyMetadata(ctx, q.metadata)
+	defer cancel()
+
+	stmt, err := r.build(ctx, q)
 	if err != nil {
-		return errors.Wrap(err, "failed to build pagination cursor")
+		return err
 	}
-	// r.kuysor = ks
-
-	queryFinal = rs.Query
-	parametersFinal = rs.Args
 
 	if r.inTransaction {
 
-		r.log.WithContext(ctx).WithParams(map[string]any{
-			"runner_code": r.runnerCode,
-			"query":       queryFinal,
-			"params":      parametersFinal,
-		}).Info("Querying query in transaction")
+		r.log.WithContext(ctx).WithParams(r.queryLogParams(stmt.query, stmt.args)).Info("Querying query in transaction")
 
 		// if in transaction, use the transaction context
 		tx, err := r.client.db.getTx(ctx)
@@ -363,22 +760,14 @@ func (r *Runner) Query(ctx context.Context) error {
 		}
 
 		// execute query
-		rows, err = tx.QueryxContext(ctx, queryFinal, parametersFinal...)
+		rows, err = tx.QueryxContext(ctx, stmt.query, stmt.args...)
 		if err != nil {
 			return errors.Wrap(err, "failed to execute query in transaction")
 		}
 
-		if r.tabling != nil && r.tabling.Pagination != nil && r.tabling.Pagination.Type == "offset" {
-			countQuery, err := kuysor.BuildCountQuery(queryParsed)
-			if err != nil {
-				return errors.Wrap(err, "failed to build count query for offset pagination")
-			}
-			r.log.WithContext(ctx).WithParams(map[string]any{
-				"runner_code": r.runnerCode,
-				"query":       countQuery,
-				"params":      parametersParsed,
-			}).Info("Querying count query for offset pagination")
-			countRow := tx.QueryRowxContext(ctx, countQuery, parametersParsed...)
+		if stmt.countQuery != "" {
+			r.log.WithContext(ctx).WithParams(r.queryLogParams(stmt.countQuery, stmt.countArgs)).Info("Querying count query for offset pagination")
+			countRow := tx.QueryRowxContext(ctx, stmt.countQuery, stmt.countArgs...)
 			err = countRow.Scan(&totalData)
 			if err != nil {
 				return errors.Wrap(err, "failed to execute count query for offset pagination")
@@ -388,29 +777,17 @@ func (r *Runner) Query(ctx context.Context) error {
 
 	} else {
 
-		r.log.WithContext(ctx).WithParams(map[string]any{
-			"runner_code": r.runnerCode,
-			"query":       queryFinal,
-			"params":      parametersFinal,
-		}).Info("Querying query")
+		r.log.WithContext(ctx).WithParams(r.queryLogParams(stmt.query, stmt.args)).Info("Querying query")
 
 		// execute query
-		rows, err = r.client.db.QueryxContext(ctx, queryFinal, parametersFinal...)
+		rows, err = r.client.db.QueryxContext(ctx, stmt.query, stmt.args...)
 		if err != nil {
 			return err
 		}
 
-		if r.tabling != nil && r.tabling.Pagination != nil && r.tabling.Pagination.Type == "offset" {
-			countQuery, err := kuysor.BuildCountQuery(queryParsed)
-			if err != nil {
-				return errors.Wrap(err, "failed to build count query for offset pagination")
-			}
-			r.log.WithContext(ctx).WithParams(map[string]any{
-				"runner_code": r.runnerCode,
-				"query":       countQuery,
-				"params":      parametersParsed,
-			}).Info("Querying count query for offset pagination")
-			countRow := r.client.db.QueryRowxContext(ctx, countQuery, parametersParsed...)
+		if stmt.countQuery != "" {
+			r.log.WithContext(ctx).WithParams(r.queryLogParams(stmt.countQuery, stmt.countArgs)).Info("Querying count query for offset pagination")
+			countRow := r.client.db.QueryRowxContext(ctx, stmt.countQuery, stmt.countArgs...)
 			err = countRow.Scan(&totalData)
 			if err != nil {
 				return errors.Wrap(err, "failed to execute count query for offset pagination")
@@ -422,12 +799,12 @@ func (r *Runner) Query(ctx context.Context) error {
 	}
 
 	responser := &responser{
-		rows:        rows,
+		rows:        &countingRows{Rows: rows},
 		mapScanFunc: MapScan,
 		jsonMarshalFunc: func(v interface{}) ([]byte, error) {
 			return json.Marshal(v)
 		},
-		kuysor:  rs,
+		kuysor:  stmt.kuysor,
 		tabling: r.tabling,
 		log:     r.log,
 	}
@@ -442,6 +819,134 @@ func (r *Runner) Query(ctx context.Context) error {
 
 }
 
+// Build renders the query and its args like Query does, including the pagination and the order by,
+// without executing it.
+func (r *Runner) Build(ctx context.Context) (string, []any, error) {
+
+	q, err := r.query()
+	if err != nil {
+		return "", nil, err
+	}
+
+	stmt, err := r.build(ctx, q)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return stmt.query, stmt.args, nil
+
+}
+
+// BuildCount renders the query counting the total rows of the offset pagination and its args, without executing it.
+// It fails if the runner has no offset pagination.
+func (r *Runner) BuildCount(ctx context.Context) (string, []any, error) {
+
+	q, err := r.query()
+	if err != nil {
+		return "", nil, err
+	}
+
+	stmt, err := r.build(ctx, q)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if stmt.countQuery == "" {
+		return "", nil, errors.New("failed to build count query: the runner has no offset pagination")
+	}
+
+	return stmt.countQuery, stmt.countArgs, nil
+
+}
+
+// BuildInline renders the query like Build, with its args inlined as SQL literals of the dialect,
+// so it can be pasted into a SQL console. It is meant for debugging only: the result must never be executed.
+func (r *Runner) BuildInline(ctx context.Context) (string, error) {
+
+	query, args, err := r.Build(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return r.client.dialect.Inline(query, args)
+
+}
+
+// queryLogParams returns the log params of a query about to be executed,
+// with the query_inline field if Option.LogInlineQuery is set.
+func (r *Runner) queryLogParams(query string, args []any) map[string]any {
+
+	params := map[string]any{
+		"runner_code": r.runnerCode,
+		"query":       query,
+		"params":      args,
+	}
+
+	if r.client.logInline {
+		inline, err := r.client.dialect.Inline(query, args)
+		if err != nil {
+			inline = "failed to inline args: " + err.Error()
+		}
+		params["query_inline"] = inline
+	}
+
+	return params
+
+}
+
+// statement is a query rendered by build, ready to be executed.
+type statement struct {
+	query string
+	args  []any
+	// countQuery counts the total rows of the offset pagination, it is empty without offset pagination
+	countQuery string
+	countArgs  []any
+	kuysor     *kuysor.Result
+}
+
+// build parses the given query and applies the pagination and the order by of the runner.
+func (r *Runner) build(ctx context.Context, q query) (*statement, error) {
+
+	// Query does not split the batch rows into chunks
+	if r.batch != nil {
+		r.setBatchRows(r.batch.rows)
+	}
+
+	r.log.WithContext(ctx).WithParams(map[string]any{
+		"runner_code": r.runnerCode,
+		"params":      r.params,
+		"placeholder": r.client.placeholder,
+	}).Debug("Parsing query")
+
+	// parse query
+	queryParsed, parametersParsed, err := r.parse(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := processTabling(ctx, r.client, r.tabling, queryParsed, parametersParsed...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build pagination cursor")
+	}
+
+	stmt := &statement{
+		query:  rs.Query,
+		args:   rs.Args,
+		kuysor: rs,
+	}
+
+	if r.tabling != nil && r.tabling.Pagination != nil && r.tabling.Pagination.Type == "offset" {
+		stmt.countQuery, err = kuysor.BuildCountQuery(queryParsed)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to build count query for offset pagination")
+		}
+		stmt.countArgs = parametersParsed
+	}
+
+	return stmt, nil
+
+}
+
 // func (r *Runner) buildTabling(ctx context.Context, query string, parameters ...any) (*kuysor.Result, error) {
 
 // 	if r.tabling == nil {
@@ -508,6 +1013,136 @@ func (r *Runner) Query(ctx context.Context) error {
 
 // }
 
+// query returns the query to run: the inline SQL query of RunSQL compiled on the fly,
+// or the query registered under the runner code.
+func (r *Runner) query() (query, error) {
+
+	if r.sql != nil {
+		return compileQuery(r.client.registry.Load().parser, r.runnerCode, *r.sql)
+	}
+
+	return r.client.runner(r.runnerCode)
+
+}
+
+// parse executes the template of the given query with the params of the runner.
+// It first returns the errors recorded by WithParams and WithPagination,
+// then validates the params against the param declarations of the runner, see Param,
+// and in strict mode, checks that the params match the params referenced by the template.
+func (r *Runner) parse(ctx context.Context, q query) (string, []any, error) {
+
+	// report the errors of the configuration of the runner, e.g. params that failed to decode,
+	// instead of validating the params without them
+	if err := stderrors.Join(r.errs...); err != nil {
+		return "", nil, err
+	}
+
+	if params := r.client.params(r.runnerCode, q); len(params) > 0 {
+		if err := validateParams(r.runnerCode, r.params, params); err != nil {
+			return "", nil, err
+		}
+	}
+
+	strict := r.client.strict
+	if r.strict != nil {
+		strict = *r.strict
+	}
+
+	if strict {
+		// the params of a template are only known once it is compiled
+		if q.tmpl == nil {
+			return "", nil, errors.Errorf("runner %s: strict mode requires a parser that implements parser.Compiler", r.runnerCode)
+		}
+		if err := r.checkParams(q.tmpl.Params()); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if len(r.identifiers) > 0 {
+		ctx = parser.ContextWithIdentifiers(ctx, r.identifiers...)
+	}
+
+	// the templates of a parser that does not compile them are parsed on every run
+	if q.tmpl == nil {
+		return r.client.registry.Load().parser.Parse(ctx, q.sql, r.params, r.client.placeholder)
+	}
+
+	return q.tmpl.Execute(ctx, r.params, r.client.placeholder)
+
+}
+
+// checkParams compares the params of the runner with the given params referenced by its template.
+// It returns a *ParamsError listing the missing and unused params, if any.
+func (r *Runner) checkParams(referenced []string) error {
+
+	var (
+		missing []string
+		unused  []string
+		known   = make(map[string]bool, len(referenced))
+	)
+
+	for _, name := range referenced {
+		known[name] = true
+		if _, ok := r.params[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	for name := range r.params {
+		if !known[name] {
+			unused = append(unused, name)
+		}
+	}
+
+	if len(missing) == 0 && len(unused) == 0 {
+		return nil
+	}
+
+	sort.Strings(unused)
+
+	return &ParamsError{
+		RunnerCode: r.runnerCode,
+		Missing:    missing,
+		Unused:     unused,
+	}
+
+}
+
+// applyMetadata honors the metadata declared in the header of the runner before it is executed.
+// It warns when the runner is deprecated and applies the timeout to the returned context.
+func (r *Runner) applyMetadata(ctx context.Context, metadata Metadata) (context.Context, context.CancelFunc) {
+
+	if metadata.Deprecated {
+		r.log.WithContext(ctx).WithParams(map[string]any{
+			"runner_code": r.runnerCode,
+			"note":        metadata.DeprecationNote,
+		}).Warn("Running deprecated runner")
+	}
+
+	if metadata.Timeout > 0 {
+		return context.WithTimeout(ctx, metadata.Timeout)
+	}
+
+	return ctx, func() {}
+
+}
+
+// checkCardinality checks that the scanner of the runner is compatible with the cardinality declared in its header.
+func (r *Runner) checkCardinality(cardinality Cardinality) error {
+
+	switch cardinality {
+	case CardinalityNone:
+		return errors.Wrapf(ErrCardinalityMismatch, "runner %s returns no rows, use Exec instead", r.runnerCode)
+	case CardinalityOne:
+		if r.scanner != nil && r.scanner.scannerType != scannerMap && r.scanner.scannerType != scannerStruct && r.scanner.scannerType != scannerValue && r.scanner.scannerType != scannerFunc {
+			return errors.Wrapf(ErrCardinalityMismatch, "runner %s returns a single row, use ScanMap, ScanStruct, ScanValue or ScanFunc instead", r.runnerCode)
+		}
+	}
+
+	return nil
+
+}
+
 // scan scans the result to the destination.
 func (r *Runner) scan(ctx context.Context, sc Scannerer) error {
 
@@ -557,7 +1192,35 @@ func (r *Runner) scan(ctx context.Context, sc Scannerer) error {
 
 		r.log.WithContext(ctx).WithParams(map[string]any{"runner_code": r.runnerCode}).Debug("scanning result into scanner writer")
 
-		err := sc.ScanWriter(r.scanner.dest.(io.Writer))
+		w := r.scanner.dest.(*writer)
+		err := sc.ScanWriter(w.dest, w.opts...)
+		if err != nil {
+			return err
+		}
+
+	case scannerValue:
+
+		r.log.WithContext(ctx).WithParams(map[string]any{"runner_code": r.runnerCode}).Debug("scanning result into scanner value")
+
+		err := sc.ScanValue(r.scanner.dest)
+		if err != nil {
+			return err
+		}
+
+	case scannerValues:
+
+		r.log.WithContext(ctx).WithParams(map[string]any{"runner_code": r.runnerCode}).Debug("scanning result into scanner values")
+
+		err := sc.ScanValues(r.scanner.dest)
+		if err != nil {
+			return err
+		}
+
+	case scannerFunc:
+
+		r.log.WithContext(ctx).WithParams(map[string]any{"runner_code": r.runnerCode}).Debug("scanning result into scanner func")
+
+		err := sc.ScanFunc(r.scanner.dest.(func(row *Row) error))
 		if err != nil {
 			return err
 		}
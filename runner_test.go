@@ -0,0 +1,531 @@
+package fayl
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redhajuanda/fayl/dialect"
+	"github.com/redhajuanda/fayl/parser"
+	"github.com/redhajuanda/perkakas/logger"
+	"github.com/redhajuanda/perkakas/pagination"
+
+	"github.com/VauntDev/tqla"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRunnerCheckParams(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{log: logger.New("test")}
+
+	t.Run("Success checking matching params", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRunner(runnerParams{runnerCode: "user.GetUser", client: client, log: client.log})
+		r.WithParam("id", 1).WithParam("name", nil)
+
+		assert.NoError(t, r.checkParams([]string{"id", "name"}))
+	})
+
+	t.Run("Failed checking missing and unused params", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRunner(runnerParams{runnerCode: "user.GetUser", client: client, log: client.log})
+		r.WithParams(map[string]any{"id": 1, "mail": "john@example.com", "nme": "john"})
+
+		err := r.checkParams([]string{"email", "id", "name"})
+
+		var paramsErr *ParamsError
+		assert.ErrorAs(t, err, &paramsErr)
+		assert.Equal(t, []string{"email", "name"}, paramsErr.Missing)
+		assert.Equal(t, []string{"mail", "nme"}, paramsErr.Unused)
+		assert.EqualError(t, err, "runner user.GetUser: missing params: email, name; unused params: mail, nme")
+	})
+}
+
+func TestRunnerBatchSize(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		dialect:     dialect.SQLServer,
+		placeholder: tqla.AtP,
+		log:         logger.New("test"),
+	}
+
+	tmpl, err := parser.New(parser.WithDialect(dialect.SQLServer)).Compile("user.InsertUsers", "INSERT INTO users {{ values .users }} -- {{ .source }}")
+	require.NoError(t, err)
+	q := query{tmpl: tmpl}
+
+	users := make([]map[string]any, 1000)
+	for i := range users {
+		users[i] = map[string]any{"id": i, "name": "john", "email": "john@example.com"}
+	}
+
+	t.Run("Success sizing the chunks under the parameter limit", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRunner(runnerParams{runnerCode: "user.InsertUsers", client: client, log: client.log})
+		r.WithParam("source", "import").WithIdentifiers("id", "name", "email").WithBatch("users", users)
+
+		size, err := r.batchSize(context.Background(), q, reflect.ValueOf(users))
+		assert.NoError(t, err)
+		assert.Equal(t, (2100-1)/3, size)
+	})
+
+	t.Run("Success keeping the params given by the caller", func(t *testing.T) {
+		t.Parallel()
+
+		params := map[string]any{"source": "import"}
+
+		r := newRunner(runnerParams{runnerCode: "user.InsertUsers", client: client, log: client.log})
+		r.WithParams(params).WithIdentifiers("id", "name", "email").WithBatch("users", users)
+
+		_, err := r.batchSize(context.Background(), q, reflect.ValueOf(users))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"source": "import"}, params)
+	})
+}
+
+func TestRunnerBuild(t *testing.T) {
+	t.Parallel()
+
+	client := &Client{
+		dialect:     dialect.Postgres,
+		placeholder: tqla.Dollar,
+		log:         logger.New("test"),
+	}
+	client.registry.Store(&registry{
+		runners: map[string]query{},
+		parser:  parser.New(),
+	})
+	require.NoError(t, client.Register("user.ListUsers", "SELECT id, name FROM users WHERE status = {{ .status }}"))
+
+	t.Run("Success building a query with offset pagination", func(t *testing.T) {
+		t.Parallel()
+
+		runner := client.Run("user.ListUsers").
+			WithParam("status", "active").
+			WithPagination(&pagination.Pagination{Type: "offset", Page: 2, PerPage: 10}).
+			WithOrderBy("-id")
+
+		query, args, err := runner.Build(context.Background())
+		assert.NoError(t, err)
+		assert.Contains(t, query, "ORDER BY id DESC")
+		assert.Contains(t, query, "LIMIT $2 OFFSET $3")
+		assert.Equal(t, []any{"active", 10, 10}, args)
+
+		countQuery, countArgs, err := runner.BuildCount(context.Background())
+		assert.NoError(t, err)
+		assert.Contains(t, countQuery, "COUNT(")
+		assert.Equal(t, []any{"active"}, countArgs)
+	})
+
+	t.Run("Success building a query without pagination", func(t *testing.T) {
+		t.Parallel()
+
+		query, args, err := client.Run("user.ListUsers").WithParam("status", "active").Build(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT id, name FROM users WHERE status = $1", query)
+		assert.Equal(t, []any{"active"}, args)
+	})
+
+	t.Run("Success building a query with inlined args", func(t *testing.T) {
+		t.Parallel()
+
+		query, err := client.Run("user.ListUsers").WithParam("status", "it's active").BuildInline(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT id, name FROM users WHERE status = 'it''s active'", query)
+	})
+
+	t.Run("Failed building the count query without offset pagination", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := client.Run("user.ListUsers").WithParam("status", "active").BuildCount(context.Background())
+		assert.EqualError(t, err, "failed to build count query: the runner has no offset pagination")
+	})
+}
+
+func TestRunnerCustomParser(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success building a query with a mocked parser", func(t *testing.T) {
+		t.Parallel()
+
+		ctrl := gomock.NewController(t)
+		mockParser := parser.NewMockParser(ctrl)
+
+		client := &Client{placeholder: tqla.Dollar, log: logger.New("test")}
+		client.registry.Store(&registry{runners: map[string]query{}, parser: mockParser})
+		require.NoError(t, client.Register("user.GetUser", "SELECT * FROM users WHERE id = :id"))
+
+		mockParser.EXPECT().
+			Parse(gomock.Any(), "SELECT * FROM users WHERE id = :id", map[string]any{"id": 1}, tqla.Dollar).
+			Return("SELECT * FROM users WHERE id = $1", []any{1}, nil)
+
+		query, args, err := client.Run("user.GetUser").WithParam("id", 1).Build(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT * FROM users WHERE id = $1", query)
+		assert.Equal(t, []any{1}, args)
+	})
+
+	t.Run("Failed running a parser that does not compile in strict mode", func(t *testing.T) {
+		t.Parallel()
+
+		client := &Client{placeholder: tqla.Dollar, log: logger.New("test")}
+		client.registry.Store(&registry{runners: map[string]query{}, parser: parser.NewNamed()})
+		require.NoError(t, client.Register("user.GetUser", "SELECT * FROM users WHERE id = :id"))
+
+		_, _, err := client.Run("user.GetUser").WithParam("id", 1).WithStrict(true).Build(context.Background())
+		assert.EqualError(t, err, "runner user.GetUser: strict mode requires a parser that implements parser.Compiler")
+	})
+
+	t.Run("Success replacing the partials of a custom parser on every reload", func(t *testing.T) {
+		t.Parallel()
+
+		custom := parser.New()
+
+		for range 3 {
+			_, err := newParser(custom, map[string]string{"user.Columns": "id, name"})
+			require.NoError(t, err)
+		}
+
+		_, err := newParser(custom, map[string]string{"user.Columns": "id"})
+		require.NoError(t, err)
+
+		query, _, err := custom.Parse(context.Background(), `SELECT {{ template "user.Columns" . }} FROM users`, nil, tqla.Dollar)
+		assert.NoError(t, err)
+		assert.Equal(t, "SELECT id FROM users", query)
+
+		// a removed partial is no longer available
+		_, err = newParser(custom, nil)
+		require.NoError(t, err)
+
+		_, _, err = custom.Parse(context.Background(), `SELECT {{ template "user.Columns" . }} FROM users`, nil, tqla.Dollar)
+		assert.Error(t, err)
+	})
+
+	t.Run("Failed adding partials to a parser that does not support them", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newParser(parser.NewNamed(), map[string]string{"user.Columns": "id, name"})
+		assert.EqualError(t, err, "the parser does not support partials")
+	})
+}
+
+func TestRunnerExecScan(t *testing.T) {
+	t.Parallel()
+
+	type user struct {
+		ID   int64  `fayl:"id"`
+		Name string `fayl:"name"`
+	}
+
+	client := newFakeClient(t, []string{"id", "name"}, []driver.Value{int64(1), "john"}, []driver.Value{int64(2), "jane"})
+	require.NoError(t, client.Register("user.InsertUsers", "INSERT INTO users {{ values .users }} RETURNING id, name"))
+
+	t.Run("Success scanning the returned rows", func(t *testing.T) {
+		t.Parallel()
+
+		var users []user
+
+		result, err := client.Run("user.InsertUsers").
+			WithIdentifiers("name").
+			WithParam("users", []map[string]any{{"name": "john"}, {"name": "jane"}}).
+			ScanStructs(&users).
+			Exec(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []user{{ID: 1, Name: "john"}, {ID: 2, Name: "jane"}}, users)
+
+		rowsAffected, err := result.RowsAffected()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), rowsAffected)
+
+		_, err = result.LastInsertId()
+		assert.Error(t, err)
+	})
+
+	t.Run("Success executing without scanner", func(t *testing.T) {
+		t.Parallel()
+
+		result, err := client.Run("user.InsertUsers").
+			WithIdentifiers("name").
+			WithParam("users", []map[string]any{{"name": "john"}, {"name": "jane"}}).
+			Exec(context.Background())
+		require.NoError(t, err)
+
+		rowsAffected, err := result.RowsAffected()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), rowsAffected)
+	})
+
+	t.Run("Success appending the rows returned by every chunk of a batch", func(t *testing.T) {
+		t.Parallel()
+
+		rows := make([]map[string]any, 1000)
+		for i := range rows {
+			rows[i] = map[string]any{"name": "john"}
+		}
+
+		var users []user
+
+		// the parameter limit of an unknown dialect is 999, so the rows are split into two chunks
+		result, err := client.Run("user.InsertUsers").
+			WithIdentifiers("name").
+			WithBatch("users", rows).
+			ScanStructs(&users).
+			Exec(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, users, 4)
+
+		rowsAffected, err := result.RowsAffected()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(4), rowsAffected)
+	})
+
+	t.Run("Failed committing the chunks of a batch", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeConnectorClient(t, &fakeConnector{commitErr: errors.New("connection reset")})
+		require.NoError(t, client.Register("user.InsertUsers", "INSERT INTO users {{ values .users }}"))
+
+		rows := make([]map[string]any, 1000)
+		for i := range rows {
+			rows[i] = map[string]any{"name": "john"}
+		}
+
+		result, err := client.Run("user.InsertUsers").
+			WithIdentifiers("name").
+			WithBatch("users", rows).
+			Exec(context.Background())
+		assert.EqualError(t, err, "failed to commit transaction: connection reset")
+		assert.Nil(t, result)
+	})
+
+	t.Run("Success counting every returned row scanned into a map", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, []driver.Value{int64(1), "john"}, []driver.Value{int64(2), "jane"}, []driver.Value{int64(3), "jack"})
+		require.NoError(t, client.Register("user.ActivateUsers", "UPDATE users SET active = TRUE RETURNING id, name"))
+
+		user := map[string]any{}
+
+		result, err := client.Run("user.ActivateUsers").ScanMap(user).Exec(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"id": int64(1), "name": "john"}, user)
+
+		rowsAffected, err := result.RowsAffected()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), rowsAffected)
+		assert.Zero(t, client.db.Stats().InUse)
+	})
+
+	t.Run("Failed scanning several returned rows into a struct", func(t *testing.T) {
+		t.Parallel()
+
+		var u user
+
+		_, err := client.Run("user.InsertUsers").
+			WithIdentifiers("name").
+			WithParam("users", []map[string]any{{"name": "john"}, {"name": "jane"}}).
+			ScanStruct(&u).
+			Exec(context.Background())
+		assert.EqualError(t, err, "failed to scan returned rows: expected 1 row, got 2")
+		assert.Zero(t, client.db.Stats().InUse)
+	})
+
+	t.Run("Failed scanning several rows returned by a single chunk batch into a struct", func(t *testing.T) {
+		t.Parallel()
+
+		var u user
+
+		_, err := client.Run("user.InsertUsers").
+			WithIdentifiers("name").
+			WithBatch("users", []map[string]any{{"name": "john"}, {"name": "jane"}}).
+			ScanStruct(&u).
+			Exec(context.Background())
+		assert.EqualError(t, err, "failed to scan returned rows: expected 1 row, got 2")
+		assert.Zero(t, client.db.Stats().InUse)
+	})
+
+	t.Run("Failed scanning the rows returned by a batch into a single row", func(t *testing.T) {
+		t.Parallel()
+
+		users := make([]map[string]any, 1000)
+		for i := range users {
+			users[i] = map[string]any{"name": "john"}
+		}
+
+		_, err := client.Run("user.InsertUsers").
+			WithIdentifiers("name").
+			WithBatch("users", users).
+			ScanMap(map[string]any{}).
+			Exec(context.Background())
+		assert.ErrorContains(t, err, "its returned rows can only be scanned with ScanMaps, ScanStructs, ScanValues or ScanFunc")
+	})
+}
+
+func TestRunnerScanValue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success scanning a single value", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"total"}, []driver.Value{int64(42)})
+		require.NoError(t, client.Register("user.CountUsers", "SELECT COUNT(*) AS total FROM users"))
+
+		var total int64
+		err := client.Run("user.CountUsers").ScanValue(&total).Query(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), total)
+	})
+
+	t.Run("Success scanning the values of a column", func(t *testing.T) {
+		t.Parallel()
+
+		createdAt := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+		client := newFakeClient(t, []string{"created_at"}, []driver.Value{createdAt}, []driver.Value{createdAt.Add(time.Hour)})
+		require.NoError(t, client.Register("user.ListCreatedAt", "SELECT created_at FROM users"))
+
+		times := []time.Time{{}}
+		err := client.Run("user.ListCreatedAt").ScanValues(&times).Query(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Time{createdAt, createdAt.Add(time.Hour)}, times)
+	})
+
+	t.Run("Success scanning a page of values", func(t *testing.T) {
+		t.Parallel()
+
+		// the count query of the offset pagination returns the same rows, so the total is the first id
+		client := newFakeClient(t, []string{"id"}, []driver.Value{int64(12)}, []driver.Value{int64(13)})
+		require.NoError(t, client.Register("user.ListUserIDs", "SELECT id FROM users"))
+
+		p := &pagination.Pagination{Type: "offset", Page: 2, PerPage: 2}
+
+		ids, err := All[int64](context.Background(), client.Run("user.ListUserIDs").WithPagination(p))
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{12, 13}, ids)
+		require.NotNil(t, p.Result)
+		assert.Equal(t, 12, p.Result.Offset.TotalData)
+		assert.Equal(t, 6, p.Result.Offset.TotalPage)
+	})
+
+	t.Run("Failed scanning values with cursor pagination", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id"}, []driver.Value{int64(1)}, []driver.Value{int64(2)}, []driver.Value{int64(3)})
+		require.NoError(t, client.Register("user.ListUserIDs", "SELECT id FROM users"))
+
+		var ids []int64
+		err := client.Run("user.ListUserIDs").
+			WithPagination(&pagination.Pagination{Type: "cursor", PerPage: 2}).
+			WithOrderBy("id").
+			ScanValues(&ids).
+			Query(context.Background())
+		assert.ErrorContains(t, err, "cursor pagination is not supported when scanning values")
+	})
+
+	t.Run("Failed scanning a value without row", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id"})
+		require.NoError(t, client.Register("user.GetUserID", "SELECT id FROM users WHERE email = {{ .email }}"))
+
+		var id int64
+		err := client.Run("user.GetUserID").WithParam("email", "john@example.com").ScanValue(&id).Query(context.Background())
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("Failed scanning a value from several columns", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, []string{"id", "name"}, []driver.Value{int64(1), "john"})
+		require.NoError(t, client.Register("user.GetUser", "SELECT id, name FROM users"))
+
+		var id int64
+		err := client.Run("user.GetUser").ScanValue(&id).Query(context.Background())
+		assert.EqualError(t, err, "expected 1 column, got 2")
+	})
+}
+
+func TestRunnerScanWriter(t *testing.T) {
+	t.Parallel()
+
+	columns := []string{"id", "name", "deleted_at"}
+	rows := [][]driver.Value{{int64(1), "john", nil}, {int64(2), "doe; jane", time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)}}
+
+	t.Run("Success writing the rows in every format", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, columns, rows...)
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name, deleted_at FROM users"))
+
+		tests := []struct {
+			opts []WriterOption
+			want string
+		}{
+			{
+				want: `[{"deleted_at":null,"id":1,"name":"john"},{"deleted_at":"2024-05-01T10:30:00Z","id":2,"name":"doe; jane"}]`,
+			},
+			{
+				opts: []WriterOption{WithFormat(FormatNDJSON)},
+				want: "{\"deleted_at\":null,\"id\":1,\"name\":\"john\"}\n{\"deleted_at\":\"2024-05-01T10:30:00Z\",\"id\":2,\"name\":\"doe; jane\"}\n",
+			},
+			{
+				opts: []WriterOption{WithFormat(FormatCSV)},
+				want: "id,name,deleted_at\n1,john,\n2,doe; jane,2024-05-01T10:30:00Z\n",
+			},
+			{
+				opts: []WriterOption{WithFormat(FormatCSV), WithDelimiter(';')},
+				want: "id;name;deleted_at\n1;john;\n2;\"doe; jane\";2024-05-01T10:30:00Z\n",
+			},
+			{
+				opts: []WriterOption{WithFormat(FormatTSV)},
+				want: "id\tname\tdeleted_at\n1\tjohn\t\n2\tdoe; jane\t2024-05-01T10:30:00Z\n",
+			},
+		}
+
+		for _, tt := range tests {
+			var buf bytes.Buffer
+			err := client.Run("user.ListUsers").ScanWriter(&buf, tt.opts...).Query(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, buf.String())
+		}
+	})
+
+	t.Run("Success writing an empty result", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, columns)
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name, deleted_at FROM users"))
+
+		var buf bytes.Buffer
+		err := client.Run("user.ListUsers").ScanWriter(&buf).Query(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", buf.String())
+
+		buf.Reset()
+		err = client.Run("user.ListUsers").ScanWriter(&buf, WithFormat(FormatCSV)).Query(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "id,name,deleted_at\n", buf.String())
+	})
+
+	t.Run("Failed writing an unsupported format", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient(t, columns, rows...)
+		require.NoError(t, client.Register("user.ListUsers", "SELECT id, name, deleted_at FROM users"))
+
+		var buf bytes.Buffer
+		err := client.Run("user.ListUsers").ScanWriter(&buf, WithFormat("xml")).Query(context.Background())
+		assert.EqualError(t, err, `unsupported writer format "xml"`)
+		assert.Empty(t, buf.String())
+	})
+}
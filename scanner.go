@@ -7,7 +7,10 @@ type Scannerer interface {
 	ScanMap(dest map[string]any) error
 	ScanStructs(dest any) error
 	ScanMaps(dest *[]map[string]any) error
-	ScanWriter(dest io.Writer) error
+	ScanWriter(dest io.Writer, opts ...WriterOption) error
+	ScanValue(dest any) error
+	ScanValues(dest any) error
+	ScanFunc(fn func(row *Row) error) error
 	Close() error
 }
 
@@ -24,6 +27,9 @@ const (
 	scannerStruct
 	scannerStructs
 	scannerWriter
+	scannerValue
+	scannerValues
+	scannerFunc
 )
 
 // newScanner returns a new scanner
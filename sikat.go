@@ -3,21 +3,80 @@ package fayl
 import (
 	"database/sql"
 	"fmt"
-	"os"
-	"path/filepath"
-	"strings"
+	"io/fs"
+	"text/template"
+	"time"
 
+	"github.com/redhajuanda/fayl/dialect"
 	"github.com/redhajuanda/fayl/parser"
 	"github.com/redhajuanda/perkakas/logger"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// QueryRoot is a directory of SQL query files mounted under an optional runner code prefix.
+type QueryRoot struct {
+	// Location is the path to the directory that contains the SQL query files.
+	Location string
+	// FS is an alternative source of SQL query files, e.g. an embed.FS.
+	// When it is set, it takes precedence over Location.
+	FS fs.FS
+	// FSRoot is the directory inside FS that contains the SQL query files.
+	// It defaults to the root of FS.
+	FSRoot string
+	// Prefix is prepended to the runner code of every query of this root,
+	// e.g. with the prefix "billing", invoice/GetInvoice.sql becomes billing.invoice.GetInvoice.
+	Prefix string
+}
+
+var (
+	// EmptySliceError fails the query when a slice param has no elements.
+	EmptySliceError = parser.EmptySliceError
+	// EmptySliceNull expands a slice param without elements to NULL, so IN ({{ .ids }}) matches no row.
+	EmptySliceNull = parser.EmptySliceNull
 )
 
 type Option struct {
 	DB            *sql.DB
 	QueryLocation string
-	DriverName    string
-	Placeholder   parser.Placeholder
+	// QueryFS is an alternative source of SQL query files, e.g. an embed.FS.
+	// When it is set, it takes precedence over QueryLocation.
+	QueryFS fs.FS
+	// QueryFSRoot is the directory inside QueryFS that contains the SQL query files.
+	// It defaults to the root of QueryFS.
+	QueryFSRoot string
+	// QueryRoots are additional directories of SQL query files, each optionally mounted under a prefix.
+	// Init fails when two roots produce the same runner code.
+	QueryRoots []QueryRoot
+	DriverName string
+	// Dialect selects the dialect-specific variants of the query files, e.g. GetUser.postgres.sql.
+	// It defaults to the dialect of DriverName.
+	Dialect     dialect.Dialect
+	Placeholder parser.Placeholder
+	// EmptySlice defines how a slice param without elements is expanded, e.g. in IN ({{ .ids }}).
+	// It defaults to EmptySliceError.
+	EmptySlice parser.EmptySlicePolicy
+	// Funcs are team-specific functions available in every query template, next to the builtin ones.
+	// A function with the same name as a builtin replaces it.
+	Funcs template.FuncMap
+	// Parser replaces the builtin template engine, e.g. with parser.NewNamed or a mock.
+	// EmptySlice, Funcs and Dialect only configure the builtin engine.
+	// The queries are compiled at Init only if the parser implements parser.Compiler, which the strict mode requires,
+	// and partials require it to implement parser.PartialSetter, whose SetPartials replaces the partials on every reload.
+	Parser parser.Parser
+	// Strict makes Exec and Query fail when a param referenced by the template is not set,
+	// or when a param is set but not referenced by the template. It can be overridden per runner with WithStrict.
+	Strict bool
+	// LogInlineQuery adds a query_inline field to the query logs, with the args inlined into the query as SQL literals,
+	// so the query can be pasted into a SQL console. It is meant for debugging, the logs then contain the values of the args.
+	LogInlineQuery bool
+	// Watch enables hot-reloading of the SQL query files while the process is running.
+	// It is meant for local development, call Client.Close to stop watching.
+	Watch bool
+	// WatchInterval is how often the query files are checked for changes when Watch is enabled.
+	// It defaults to one second.
+	WatchInterval time.Duration
 }
 
 // Init initializes a new fayl client.
@@ -35,60 +94,92 @@ func initFayl(log logger.Logger, opt Option) (*Client, error) {
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
 
-	// Initialize the runners map to store SQL queries
-	var runners = make(map[string]string)
-
-	// Check if QueryLocation exists
-	if _, err := os.Stat(opt.QueryLocation); os.IsNotExist(err) {
-		return nil, fmt.Errorf("query location does not exist: %s", opt.QueryLocation)
+	// Resolve the directories of the SQL query files
+	sources, err := opt.querySources()
+	if err != nil {
+		return nil, err
 	}
 
-	// Walk through the directory and its subdirectories
-	err := filepath.Walk(opt.QueryLocation, func(path string, info os.FileInfo, err error) error {
+	// Resolve the dialect used to pick the query variants
+	d := opt.Dialect
+	if d == "" {
+		d = dialect.FromDriver(opt.DriverName)
+	}
 
-		if err != nil {
-			return err
-		}
+	// Load the SQL queries into the runners map
+	runners, partials, err := loadQueries(sources, d)
+	if err != nil {
+		return nil, err
+	}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+	// Compile every query template once, so they are validated upfront and reused on every run
+	parserOptions := []parser.Option{
+		parser.WithEmptySlice(opt.EmptySlice),
+		parser.WithFuncs(opt.Funcs),
+		parser.WithDialect(d),
+	}
+	p, err := newParser(opt.Parser, partials, parserOptions...)
+	if err != nil {
+		return nil, err
+	}
 
-		// Process only .sql files
-		if strings.ToLower(filepath.Ext(path)) == ".sql" {
-			// Read the SQL file
-			content, err := os.ReadFile(path)
-			if err != nil {
-				return fmt.Errorf("error reading file %s: %v", path, err)
-			}
-
-			// Get the relative path from QueryLocation
-			relPath, err := filepath.Rel(opt.QueryLocation, path)
-			if err != nil {
-				return fmt.Errorf("error getting relative path: %v", err)
-			}
-
-			// Create the key by removing the extension and replacing path separators with dots
-			key := strings.TrimSuffix(relPath, filepath.Ext(relPath))
-			key = strings.ReplaceAll(key, string(os.PathSeparator), ".")
-
-			// Store the SQL query in the runners map
-			runners[key] = string(content)
-		}
+	err = compileQueries(p, runners)
+	if err != nil {
+		return nil, err
+	}
 
-		return nil
+	client := &Client{
+		db:            &DB{DB: db},
+		dialect:       d,
+		parserOptions: parserOptions,
+		parser:        opt.Parser,
+		strict:        opt.Strict,
+		logInline:     opt.LogInlineQuery,
+		placeholder:   opt.Placeholder,
+		log:           log,
+	}
+	client.registry.Store(&registry{
+		runners: runners,
+		parser:  p,
 	})
 
-	if err != nil {
-		return nil, fmt.Errorf("error walking through query location: %v", err)
+	// Start watching the query files if hot-reloading is enabled
+	if opt.Watch {
+		client.watcher = newWatcher(client, sources, opt.WatchInterval)
+		go client.watcher.run()
+	}
+
+	return client, nil
+
+}
+
+// querySources returns the directories of SQL query files configured in the option.
+// QueryFS or QueryLocation is mounted without prefix, followed by QueryRoots.
+func (opt Option) querySources() ([]querySource, error) {
+
+	var roots []QueryRoot
+
+	if opt.QueryFS != nil || opt.QueryLocation != "" {
+		roots = append(roots, QueryRoot{
+			Location: opt.QueryLocation,
+			FS:       opt.QueryFS,
+			FSRoot:   opt.QueryFSRoot,
+		})
+	}
+	roots = append(roots, opt.QueryRoots...)
+
+	if len(roots) == 0 {
+		return nil, errors.New("query location is required")
+	}
+
+	sources := make([]querySource, 0, len(roots))
+	for _, qr := range roots {
+		if qr.FS == nil && qr.Location == "" {
+			return nil, errors.New("query root must have either a location or a file system")
+		}
+		sources = append(sources, newQuerySource(qr))
 	}
 
-	return &Client{
-		db:          &DB{DB: db},
-		runners:     runners,
-		placeholder: opt.Placeholder,
-		log:         log,
-	}, nil
+	return sources, nil
 
 }
@@ -36,3 +36,16 @@ func (t *Tx) Run(runnerCode string) Runnerer {
 	})
 
 }
+
+// RunSQL is a function to run the given SQL query within the transaction
+func (t *Tx) RunSQL(sql string) Runnerer {
+
+	return newRunner(runnerParams{
+		runnerCode:    inlineRunnerCode(sql),
+		sql:           &sql,
+		client:        t.client,
+		log:           t.log,
+		inTransaction: true,
+	})
+
+}
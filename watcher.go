@@ -0,0 +1,217 @@
+package fayl
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultWatchInterval is the default interval between two checks of the query files.
+const defaultWatchInterval = time.Second
+
+// watcher polls the query files and reloads the client runners when they change.
+type watcher struct {
+	client      *Client
+	sources     []querySource
+	interval    time.Duration
+	fingerprint string
+	failed      string
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+// newWatcher returns a new watcher for the query files of the given sources.
+func newWatcher(client *Client, sources []querySource, interval time.Duration) *watcher {
+
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	w := &watcher{
+		client:   client,
+		sources:  sources,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+
+	// take the initial fingerprint so the first tick does not reload unchanged files
+	w.fingerprint, _ = w.fingerprintFiles()
+
+	return w
+
+}
+
+// run checks the query files on every tick until the watcher is stopped.
+func (w *watcher) run() {
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+
+}
+
+// stop stops the watcher, it is safe to call it more than once.
+func (w *watcher) stop() {
+
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+
+}
+
+// check reloads the runners if the query files changed since the last check.
+func (w *watcher) check() {
+
+	fingerprint, err := w.fingerprintFiles()
+	if err != nil {
+		w.client.log.WithParams(map[string]any{"error": err.Error()}).Error("failed to check query files for changes")
+		return
+	}
+
+	// the files that failed to reload are reported once, they are reloaded again when they change
+	if fingerprint == w.fingerprint || fingerprint == w.failed {
+		return
+	}
+
+	w.client.log.Info("query files changed, reloading runners")
+
+	// keep the previous fingerprint if the reload fails, so a file read while an editor is still saving it
+	// is reloaded on the next check, as the end of the save changes its size or modification time
+	err = w.reload()
+	if err != nil {
+		w.client.log.WithParams(map[string]any{"error": err.Error()}).Error("failed to reload runners")
+		w.failed = fingerprint
+		return
+	}
+	w.fingerprint, w.failed = fingerprint, ""
+
+}
+
+// reload loads and compiles the query files again and atomically swaps the client registry.
+// A query that fails to compile is reported and its previously loaded version is kept,
+// a partial that fails to parse aborts the reload. The runners registered with Register are kept.
+func (w *watcher) reload() error {
+
+	loaded, partials, err := loadQueries(w.sources, w.client.dialect)
+	if err != nil {
+		return err
+	}
+
+	p, err := newParser(w.client.parser, partials, w.client.parserOptions...)
+	if err != nil {
+		return err
+	}
+
+	// hold the lock until the new registry is stored, so a concurrent Register is not lost
+	w.client.mu.Lock()
+	defer w.client.mu.Unlock()
+
+	var (
+		previous = w.client.registry.Load().runners
+		runners  = make(map[string]query, len(loaded)+len(w.client.registered))
+	)
+
+	for code, q := range loaded {
+
+		tmpl, err := compileTemplate(p, code, q.sql)
+		if err != nil {
+
+			w.client.log.WithParams(map[string]any{
+				"runner_code": code,
+				"error":       err.Error(),
+			}).Error("failed to compile query, keeping the previous version")
+
+			if prev, ok := previous[code]; ok {
+				runners[code] = prev
+			}
+			continue
+
+		}
+
+		q.tmpl = tmpl
+		runners[code] = q
+
+	}
+
+	// compile the runners registered with Register again, they may include partials that changed
+	for code, sql := range w.client.registered {
+
+		if _, ok := runners[code]; ok {
+			w.client.log.WithParams(map[string]any{
+				"runner_code": code,
+			}).Error("runner code is both registered and loaded from a file, keeping the registered one")
+		}
+
+		q, err := compileQuery(p, code, sql)
+		if err != nil {
+
+			w.client.log.WithParams(map[string]any{
+				"runner_code": code,
+				"error":       err.Error(),
+			}).Error("failed to compile registered query, keeping the previous version")
+
+			runners[code] = previous[code]
+			continue
+
+		}
+
+		runners[code] = q
+
+	}
+
+	w.client.registry.Store(&registry{
+		runners: runners,
+		parser:  p,
+	})
+
+	return nil
+
+}
+
+// fingerprintFiles returns a string that changes whenever a query file is added, removed or modified.
+func (w *watcher) fingerprintFiles() (string, error) {
+
+	var sb strings.Builder
+
+	for _, src := range w.sources {
+
+		err := fs.WalkDir(src.fsys, src.root, func(filePath string, d fs.DirEntry, err error) error {
+
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() || strings.ToLower(path.Ext(filePath)) != ".sql" {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(&sb, "%s|%d|%d\n", src.describe(filePath), info.Size(), info.ModTime().UnixNano())
+
+			return nil
+
+		})
+		if err != nil {
+			return "", err
+		}
+
+	}
+
+	return sb.String(), nil
+
+}
@@ -0,0 +1,60 @@
+package fayl
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/redhajuanda/fayl/parser"
+	"github.com/redhajuanda/perkakas/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatcherCheck(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"user/GetUser.sql":   {Data: []byte("SELECT * FROM users WHERE id = {{ .id }}"), ModTime: time.Unix(1, 0)},
+		"user/ListUsers.sql": {Data: []byte("SELECT * FROM users"), ModTime: time.Unix(1, 0)},
+	}
+
+	runners, _, err := loadQueries([]querySource{newQuerySource(QueryRoot{FS: fsys})}, "")
+	assert.NoError(t, err)
+
+	client := &Client{log: logger.New("test")}
+	client.registry.Store(&registry{runners: runners, parser: parser.New()})
+
+	w := newWatcher(client, []querySource{newQuerySource(QueryRoot{FS: fsys})}, time.Hour)
+
+	// a broken template keeps the previous version, a new file is picked up
+	fsys["user/GetUser.sql"] = &fstest.MapFile{Data: []byte("SELECT * FROM users WHERE id = {{ .id "), ModTime: time.Unix(2, 0)}
+	fsys["user/CountUsers.sql"] = &fstest.MapFile{Data: []byte("SELECT COUNT(*) FROM users"), ModTime: time.Unix(2, 0)}
+	w.check()
+
+	runners = client.registry.Load().runners
+	assert.Equal(t, "SELECT * FROM users WHERE id = {{ .id }}", runners["user.GetUser"].sql)
+	assert.Equal(t, "SELECT COUNT(*) FROM users", runners["user.CountUsers"].sql)
+
+	// a removed file is dropped
+	delete(fsys, "user/ListUsers.sql")
+	w.check()
+
+	assert.False(t, client.HasRunner("user.ListUsers"))
+	assert.Equal(t, []string{"user.CountUsers", "user.GetUser"}, client.Runners())
+
+	// a failed reload is reported once, and retried when the files change
+	fingerprint := w.fingerprint
+	fsys["user/_partials/Columns.sql"] = &fstest.MapFile{Data: []byte("id, name {{ end }}"), ModTime: time.Unix(3, 0)}
+	w.check()
+	assert.Equal(t, fingerprint, w.fingerprint)
+	assert.NotEmpty(t, w.failed)
+
+	failed := w.failed
+	w.check()
+	assert.Equal(t, failed, w.failed)
+
+	fsys["user/_partials/Columns.sql"] = &fstest.MapFile{Data: []byte("id, name"), ModTime: time.Unix(3, 0)}
+	w.check()
+	assert.NotEqual(t, fingerprint, w.fingerprint)
+	assert.Empty(t, w.failed)
+}
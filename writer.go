@@ -0,0 +1,214 @@
+package fayl
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WriterFormat is the output format of ScanWriter.
+type WriterFormat string
+
+const (
+	// FormatJSON writes the rows as a JSON array of objects, it is the default format.
+	FormatJSON WriterFormat = "json"
+	// FormatNDJSON writes every row as a JSON object on its own line.
+	FormatNDJSON WriterFormat = "ndjson"
+	// FormatCSV writes a header with the column names, then every row as comma-separated values.
+	FormatCSV WriterFormat = "csv"
+	// FormatTSV writes a header with the column names, then every row as tab-separated values.
+	FormatTSV WriterFormat = "tsv"
+)
+
+// WriterOption configures the output of ScanWriter.
+type WriterOption func(*writerOptions)
+
+type writerOptions struct {
+	format    WriterFormat
+	delimiter rune
+}
+
+// WithFormat sets the output format of ScanWriter. It defaults to FormatJSON.
+func WithFormat(format WriterFormat) WriterOption {
+
+	return func(o *writerOptions) {
+		o.format = format
+	}
+
+}
+
+// WithDelimiter sets the field delimiter of FormatCSV, e.g. ';'. It defaults to ','.
+func WithDelimiter(delimiter rune) WriterOption {
+
+	return func(o *writerOptions) {
+		o.delimiter = delimiter
+	}
+
+}
+
+// writer is the destination of a runner scanned with ScanWriter.
+type writer struct {
+	dest io.Writer
+	opts []WriterOption
+}
+
+// rowWriter writes the rows of a result set to a writer in a given format, one row at a time.
+type rowWriter interface {
+	writeRow(row map[string]any) error
+	close() error
+}
+
+// newRowWriter returns a rowWriter writing the rows with the given columns to dest.
+// The writes are buffered, so the memory used does not depend on the number of rows.
+func newRowWriter(dest io.Writer, columns []string, marshal func(v any) ([]byte, error), opts ...WriterOption) (rowWriter, error) {
+
+	options := writerOptions{
+		format:    FormatJSON,
+		delimiter: ',',
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	buf := bufio.NewWriter(dest)
+
+	switch options.format {
+	case FormatJSON, FormatNDJSON:
+		return &jsonWriter{
+			buf:     buf,
+			marshal: marshal,
+			ndjson:  options.format == FormatNDJSON,
+		}, nil
+	case FormatCSV, FormatTSV:
+		w := csv.NewWriter(buf)
+		w.Comma = options.delimiter
+		if options.format == FormatTSV {
+			w.Comma = '\t'
+		}
+
+		cw := &csvWriter{
+			buf:     buf,
+			w:       w,
+			columns: columns,
+			record:  make([]string, len(columns)),
+			marshal: marshal,
+		}
+		if err := cw.w.Write(columns); err != nil {
+			return nil, errors.Wrap(err, "failed to write header")
+		}
+
+		return cw, nil
+	}
+
+	return nil, errors.Errorf("unsupported writer format %q", options.format)
+
+}
+
+// jsonWriter writes the rows as a JSON array, or as newline-delimited JSON objects.
+type jsonWriter struct {
+	buf     *bufio.Writer
+	marshal func(v any) ([]byte, error)
+	ndjson  bool
+	rows    int
+}
+
+func (w *jsonWriter) writeRow(row map[string]any) error {
+
+	b, err := w.marshal(row)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal row to JSON")
+	}
+
+	switch {
+	case w.ndjson:
+	case w.rows == 0:
+		w.buf.WriteByte('[')
+	default:
+		w.buf.WriteByte(',')
+	}
+	w.rows++
+
+	// stop scanning the rows as soon as the writer fails
+	if _, err := w.buf.Write(b); err != nil {
+		return errors.Wrap(err, "failed to write JSON to writer")
+	}
+	if w.ndjson {
+		w.buf.WriteByte('\n')
+	}
+
+	return nil
+
+}
+
+func (w *jsonWriter) close() error {
+
+	if !w.ndjson {
+		if w.rows == 0 {
+			w.buf.WriteByte('[')
+		}
+		w.buf.WriteByte(']')
+	}
+
+	return errors.Wrap(w.buf.Flush(), "failed to write JSON to writer")
+
+}
+
+// csvWriter writes the rows as delimiter-separated values, in the order of the columns.
+type csvWriter struct {
+	buf     *bufio.Writer
+	w       *csv.Writer
+	columns []string
+	record  []string
+	marshal func(v any) ([]byte, error)
+}
+
+func (w *csvWriter) writeRow(row map[string]any) error {
+
+	for i, column := range w.columns {
+		field, err := w.field(row[column])
+		if err != nil {
+			return errors.Wrapf(err, "failed to format column %s", column)
+		}
+		w.record[i] = field
+	}
+
+	return errors.Wrap(w.w.Write(w.record), "failed to write row")
+
+}
+
+// field formats a value of a row as a CSV field.
+// NULL is an empty field, and JSON columns are written as JSON.
+func (w *csvWriter) field(value any) (string, error) {
+
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case map[string]any, []map[string]any:
+		b, err := w.marshal(v)
+		return string(b), err
+	}
+
+	return fmt.Sprint(value), nil
+
+}
+
+func (w *csvWriter) close() error {
+
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return errors.Wrap(err, "failed to write rows")
+	}
+
+	return errors.Wrap(w.buf.Flush(), "failed to write rows to writer")
+
+}